@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	accessReportSelector projectSelector
+	accessReportFormat   string
+	accessReportLayout   string
+)
+
+var accessReportCmd = &cobra.Command{
+	Use:   "access-report",
+	Short: "Report the effective access of every user on the projects",
+	Long: `Report the effective access of every user on the projects
+
+  The effective access level comes from the project members/all endpoint and
+  its source is the grant giving that level:
+
+    direct            the user is a project member
+    group:<path>      the user is a member of the project group or its parents
+    shared:<path>     the user is a member of a group the project is shared with,
+                      limited to the group share access level
+
+  The list layout prints a row per user and project and the matrix layout
+  prints a row per user with the access level on every project.`,
+	Example: `  Report the access matrix of test1 group projects as a table
+
+  gitlab-api-client access-report \
+    --group test1 \
+    --layout matrix \
+    --format table \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+	RunE: doAccessReport,
+}
+
+func init() {
+	rootCmd.AddCommand(accessReportCmd)
+	accessReportSelector.addFlags(accessReportCmd)
+	accessReportCmd.Flags().StringVarP(&accessReportFormat, "format", "F", "csv", "The report format (csv or table, tab separated)")
+	accessReportCmd.Flags().StringVar(&accessReportLayout, "layout", "list", "The report layout (list or matrix)")
+}
+
+// accessGrant is the access given to a user on a project by a source
+type accessGrant struct {
+	level  gitlab.AccessLevelValue
+	source string
+}
+
+// projectAccess is the effective access of a user on a project
+type projectAccess struct {
+	username string
+	name     string
+	level    gitlab.AccessLevelValue
+	grants   []*accessGrant
+}
+
+// sources returns the sources of the grants giving the effective level
+func (a *projectAccess) sources() string {
+	sources := make([]string, 0)
+	for _, grant := range a.grants {
+		if grant.level == a.level {
+			sources = addUnique(sources, grant.source)
+		}
+	}
+	if len(sources) == 0 {
+		return "inherited"
+	}
+	return strings.Join(sources, " ")
+}
+
+// accessResolver resolves the effective access on projects, caching the groups and their members
+type accessResolver struct {
+	helper       *gitlabapi.GitlabApi
+	groups       map[int]*gitlab.Group
+	groupMembers map[int][]*gitlab.GroupMember
+}
+
+// sharedGroup returns the group the project is shared with, which only has its name in the project
+func (r *accessResolver) sharedGroup(id int) (*gitlab.Group, error) {
+	if group, ok := r.groups[id]; ok {
+		return group, nil
+	}
+	group, err := r.helper.FindGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, errors.Errorf("shared group %d not found", id)
+	}
+	r.groups[id] = group
+	return group, nil
+}
+
+func (r *accessResolver) listGroupMembers(group *gitlab.Group) ([]*gitlab.GroupMember, error) {
+	if members, ok := r.groupMembers[group.ID]; ok {
+		return members, nil
+	}
+	members, err := r.helper.ListGroupMembers(group, true)
+	if err != nil {
+		return nil, err
+	}
+	r.groupMembers[group.ID] = members
+	return members, nil
+}
+
+func (r *accessResolver) resolve(project *gitlab.Project) ([]*projectAccess, error) {
+	effective, err := r.helper.ListProjectMembers(project, true)
+	if err != nil {
+		return nil, err
+	}
+	grants := make(map[int][]*accessGrant)
+	direct, err := r.helper.ListProjectMembers(project, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range direct {
+		grants[member.ID] = append(grants[member.ID], &accessGrant{member.AccessLevel, "direct"})
+	}
+	if project.Namespace != nil && project.Namespace.Kind == "group" {
+		group := &gitlab.Group{ID: project.Namespace.ID, FullPath: project.Namespace.FullPath}
+		members, err := r.listGroupMembers(group)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			grants[member.ID] = append(grants[member.ID], &accessGrant{member.AccessLevel, "group:" + group.FullPath})
+		}
+	}
+	for _, shared := range project.SharedWithGroups {
+		group, err := r.sharedGroup(shared.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		members, err := r.listGroupMembers(group)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			level := member.AccessLevel
+			if limit := gitlab.AccessLevelValue(shared.GroupAccessLevel); limit < level {
+				level = limit
+			}
+			grants[member.ID] = append(grants[member.ID], &accessGrant{level, "shared:" + group.FullPath})
+		}
+	}
+	access := make([]*projectAccess, 0, len(effective))
+	for _, member := range effective {
+		access = append(access, &projectAccess{
+			username: member.Username,
+			name:     member.Name,
+			level:    member.AccessLevel,
+			grants:   grants[member.ID],
+		})
+	}
+	return access, nil
+}
+
+func printReportRow(format string, row []string) error {
+	switch format {
+	case "csv":
+		return utils.PrintCSV(row)
+	case "table":
+		fmt.Println(strings.Join(row, "\t"))
+		return nil
+	default:
+		return errors.Errorf("unknown report format: %s", format)
+	}
+}
+
+func doAccessReport(cmd *cobra.Command, args []string) error {
+	if accessReportLayout != "list" && accessReportLayout != "matrix" {
+		return errors.Errorf("unknown report layout: %s (list or matrix)", accessReportLayout)
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := accessReportSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	resolver := &accessResolver{helper: gitlabAPI, groups: make(map[int]*gitlab.Group), groupMembers: make(map[int][]*gitlab.GroupMember)}
+	matrix := make(map[string]map[int]gitlab.AccessLevelValue)
+	if accessReportLayout == "list" {
+		if err := printReportRow(accessReportFormat, []string{"username", "name", "project", "access", "source"}); err != nil {
+			return err
+		}
+	}
+	for _, project := range projects {
+		access, err := resolver.resolve(project)
+		if err != nil {
+			return errors.Wrapf(err, "resolving project %q access", project.PathWithNamespace)
+		}
+		for _, a := range access {
+			if accessReportLayout == "list" {
+				row := []string{a.username, a.name, project.PathWithNamespace, accessLevelName(a.level), a.sources()}
+				if err := printReportRow(accessReportFormat, row); err != nil {
+					return err
+				}
+				continue
+			}
+			if matrix[a.username] == nil {
+				matrix[a.username] = make(map[int]gitlab.AccessLevelValue)
+			}
+			matrix[a.username][project.ID] = a.level
+		}
+	}
+	if accessReportLayout == "list" {
+		return nil
+	}
+	header := []string{"username"}
+	for _, project := range projects {
+		header = append(header, project.PathWithNamespace)
+	}
+	if err := printReportRow(accessReportFormat, header); err != nil {
+		return err
+	}
+	usernames := make([]string, 0, len(matrix))
+	for username := range matrix {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	for _, username := range usernames {
+		row := []string{username}
+		for _, project := range projects {
+			level, ok := matrix[username][project.ID]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, accessLevelName(level))
+		}
+		if err := printReportRow(accessReportFormat, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
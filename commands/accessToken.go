@@ -0,0 +1,428 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	accessTokenSelector        projectSelector
+	accessTokenScope           string
+	accessTokenFormat          string
+	accessTokenName            string
+	accessTokenID              int
+	accessTokenScopes          []string
+	accessTokenAccessLevel     string
+	accessTokenExpiresAt       string
+	accessTokenDays            int
+	accessTokenVariableProject string
+	accessTokenVariable        string
+)
+
+var accessTokenCmd = &cobra.Command{
+	Use:   "access-token",
+	Short: "Gitlab projects and groups access tokens",
+	Example: `  Rotate the ci token of group test1 projects, saving the new one in the
+  CI_TOKEN variable of every project
+
+  gitlab-api-client access-token rotate \
+    --group test1 \
+    --name ci \
+    --expires-at 2021-12-31 \
+    --variable CI_TOKEN \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Rotate the ci token of the test1 group, saving the new one in the CI_TOKEN
+  variable of the test1/deployer project
+
+  gitlab-api-client access-token rotate --scope group --group '^test1$' --name ci --variable-project test1/deployer --variable CI_TOKEN
+
+  Report the group access tokens expiring in the next 30 days
+
+  gitlab-api-client access-token expiring --scope group --days 30`,
+}
+
+var accessTokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the active access tokens of projects or groups",
+	RunE:  doAccessTokenList,
+}
+
+var accessTokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an access token in projects or groups",
+	Long: `Create an access token in projects or groups
+
+  The token values are only shown once, in the report of the command.`,
+	RunE: doAccessTokenCreate,
+}
+
+var accessTokenRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace an access token by a new one with the same scopes and role",
+	Long: `Replace an access token by a new one with the same scopes and role
+
+  The new token expires at --expires-at, or after the same lifetime of the old
+  one when it is not given. With --variable it is written in the CI/CD variable
+  of the --variable-project project (the project itself when empty). The old
+  token is revoked only when the previous steps succeed.`,
+	RunE: doAccessTokenRotate,
+}
+
+var accessTokenRevokeCmd = &cobra.Command{
+	Use:     "revoke",
+	Short:   "Revoke an access token, by id or name, in projects or groups",
+	Aliases: []string{"delete"},
+	RunE:    doAccessTokenRevoke,
+}
+
+var accessTokenExpiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "Report the access tokens expiring within some days",
+	RunE:  doAccessTokenExpiring,
+}
+
+func init() {
+	rootCmd.AddCommand(accessTokenCmd)
+	for _, cmd := range []*cobra.Command{accessTokenListCmd, accessTokenCreateCmd, accessTokenRotateCmd, accessTokenRevokeCmd, accessTokenExpiringCmd} {
+		accessTokenCmd.AddCommand(cmd)
+		accessTokenSelector.addFlags(cmd)
+		cmd.Flags().StringVar(&accessTokenScope, "scope", "project", "The tokens owner (project or group)")
+	}
+	for _, cmd := range []*cobra.Command{accessTokenListCmd, accessTokenExpiringCmd} {
+		cmd.Flags().StringVarP(&accessTokenFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+		addProfilesFlag(cmd)
+	}
+	for _, cmd := range []*cobra.Command{accessTokenCreateCmd, accessTokenRotateCmd, accessTokenRevokeCmd} {
+		cmd.Flags().StringVarP(&accessTokenName, "name", "n", "", "The access token name")
+	}
+	for _, cmd := range []*cobra.Command{accessTokenRotateCmd, accessTokenRevokeCmd} {
+		cmd.Flags().IntVar(&accessTokenID, "token-id", 0, "The access token id")
+	}
+	for _, cmd := range []*cobra.Command{accessTokenCreateCmd, accessTokenRotateCmd} {
+		cmd.Flags().StringVar(&accessTokenExpiresAt, "expires-at", "", "The access token expiration date (YYYY-MM-DD)")
+	}
+	accessTokenCreateCmd.MarkFlagRequired("name")
+	accessTokenCreateCmd.Flags().StringSliceVar(&accessTokenScopes, "scopes", []string{"read_api"}, "The access token scopes (api, read_api, read_registry, write_registry, read_repository, write_repository)")
+	accessTokenCreateCmd.Flags().StringVarP(&accessTokenAccessLevel, "access", "L", "maintainer", "The access token role, by name or number")
+	accessTokenRotateCmd.Flags().StringVar(&accessTokenVariableProject, "variable-project", "", "The project (path or id) where the new token is written, for a single owner (default the owner project)")
+	accessTokenRotateCmd.Flags().StringVar(&accessTokenVariable, "variable", "", "The CI/CD variable where the new token is written")
+	accessTokenExpiringCmd.Flags().IntVar(&accessTokenDays, "days", 30, "The days to report the tokens expiring within")
+}
+
+type accessTokenRecord struct {
+	Scope       string   `json:"scope"`
+	Path        string   `json:"path"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	AccessLevel int      `json:"access_level"`
+	ExpiresAt   string   `json:"expires_at"`
+}
+
+func newAccessTokenRecord(o *owner, token *gitlabapi.AccessToken) *accessTokenRecord {
+	return &accessTokenRecord{
+		Scope:       o.scope(),
+		Path:        o.path(),
+		ID:          token.ID,
+		Name:        token.Name,
+		Scopes:      token.Scopes,
+		AccessLevel: int(token.AccessLevel),
+		ExpiresAt:   accessTokenExpiration(token),
+	}
+}
+
+func accessTokenExpiration(token *gitlabapi.AccessToken) string {
+	if token.ExpiresAt == nil {
+		return ""
+	}
+	return time.Time(*token.ExpiresAt).Format("2006-01-02")
+}
+
+func (r *accessTokenRecord) CSV() []string {
+	return []string{r.Scope, r.Path, strconv.Itoa(r.ID), r.Name, strings.Join(r.Scopes, " "), strconv.Itoa(r.AccessLevel), r.ExpiresAt}
+}
+
+func (r *accessTokenRecord) Plain() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%s:%d:%s", r.Scope, r.Path, r.ID, r.Name, strings.Join(r.Scopes, ","), r.AccessLevel, r.ExpiresAt)
+}
+
+// listAccessTokens returns the active tokens of the owner
+func listAccessTokens(helper *gitlabapi.GitlabApi, o *owner) ([]*gitlabapi.AccessToken, error) {
+	var tokens []*gitlabapi.AccessToken
+	var err error
+	if o.group != nil {
+		tokens, err = helper.ListGroupAccessTokens(o.group)
+	} else {
+		tokens, err = helper.ListProjectAccessTokens(o.project)
+	}
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*gitlabapi.AccessToken, 0)
+	for _, token := range tokens {
+		if token.Active && !token.Revoked {
+			active = append(active, token)
+		}
+	}
+	return active, nil
+}
+
+func createAccessToken(helper *gitlabapi.GitlabApi, o *owner, opts *gitlabapi.CreateAccessTokenOptions) (*gitlabapi.AccessToken, error) {
+	if o.group != nil {
+		return helper.CreateGroupAccessToken(o.group, opts)
+	}
+	return helper.CreateProjectAccessToken(o.project, opts)
+}
+
+func revokeAccessToken(helper *gitlabapi.GitlabApi, o *owner, token *gitlabapi.AccessToken) error {
+	if o.group != nil {
+		return helper.RevokeGroupAccessToken(o.group, token)
+	}
+	return helper.RevokeProjectAccessToken(o.project, token)
+}
+
+// findAccessToken returns the owner token with the id or name flags, or nil
+func findAccessToken(helper *gitlabapi.GitlabApi, o *owner) (*gitlabapi.AccessToken, error) {
+	tokens, err := listAccessTokens(helper, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if (accessTokenID == 0 || token.ID == accessTokenID) && (accessTokenName == "" || token.Name == accessTokenName) {
+			return token, nil
+		}
+	}
+	return nil, nil
+}
+
+func parseExpiresAt(date string) (*gitlab.ISOTime, error) {
+	if date == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing expiration date %q", date)
+	}
+	expiresAt := gitlab.ISOTime(t)
+	return &expiresAt, nil
+}
+
+func selectAccessTokenOwners() (*gitlabapi.GitlabApi, []*owner, error) {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := accessTokenSelector.selectOwners(gitlabAPI, accessTokenScope)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "selecting owners")
+	}
+	return gitlabAPI, owners, nil
+}
+
+// printAccessTokens prints the tokens of the selected owners accepted by the filter
+func printAccessTokens(filter func(*gitlabapi.AccessToken) bool) error {
+	gitlabAPI, owners, err := selectAccessTokenOwners()
+	if err != nil {
+		return err
+	}
+	for _, o := range owners {
+		tokens, err := listAccessTokens(gitlabAPI, o)
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if !filter(token) {
+				continue
+			}
+			if err := printRecord(accessTokenFormat, newAccessTokenRecord(o, token)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func doAccessTokenList(cmd *cobra.Command, args []string) error {
+	return printAccessTokens(func(*gitlabapi.AccessToken) bool {
+		return true
+	})
+}
+
+func doAccessTokenExpiring(cmd *cobra.Command, args []string) error {
+	limit := time.Now().AddDate(0, 0, accessTokenDays)
+	return printAccessTokens(func(token *gitlabapi.AccessToken) bool {
+		return token.ExpiresAt != nil && time.Time(*token.ExpiresAt).Before(limit)
+	})
+}
+
+func doAccessTokenCreate(cmd *cobra.Command, args []string) error {
+	expiresAt, err := parseExpiresAt(accessTokenExpiresAt)
+	if err != nil {
+		return err
+	}
+	level, err := parseAccessLevel(accessTokenAccessLevel)
+	if err != nil {
+		return err
+	}
+	opts := &gitlabapi.CreateAccessTokenOptions{
+		Name:        &accessTokenName,
+		Scopes:      accessTokenScopes,
+		AccessLevel: &level,
+		ExpiresAt:   expiresAt,
+	}
+	gitlabAPI, owners, err := selectAccessTokenOwners()
+	if err != nil {
+		return err
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		token, err := createAccessToken(gitlabAPI, o, opts)
+		if err != nil {
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{o.path(), "ok", strconv.Itoa(token.ID), token.Token})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
+
+// rotateAccessToken replaces the owner token, returning the new one
+func rotateAccessToken(helper *gitlabapi.GitlabApi, o *owner, variableProject *gitlab.Project) (*gitlabapi.AccessToken, error) {
+	old, err := findAccessToken(helper, o)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, nil
+	}
+	expiresAt, err := parseExpiresAt(accessTokenExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt == nil && old.ExpiresAt != nil && old.CreatedAt != nil {
+		lifetime := time.Time(*old.ExpiresAt).Sub(*old.CreatedAt)
+		t := gitlab.ISOTime(time.Now().Add(lifetime))
+		expiresAt = &t
+	}
+	level := old.AccessLevel
+	token, err := createAccessToken(helper, o, &gitlabapi.CreateAccessTokenOptions{
+		Name:        &old.Name,
+		Scopes:      old.Scopes,
+		AccessLevel: &level,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("created access token %q (%d) in %s %q", token.Name, token.ID, o.scope(), o.path())
+	if accessTokenVariable != "" {
+		project := variableProject
+		if project == nil {
+			project = o.project
+		}
+		if err := helper.SetProjectVariable(project, accessTokenVariable, token.Token, true); err != nil {
+			return token, errors.Wrapf(err, "new token %d created but the old one was not revoked", token.ID)
+		}
+		log.Infof("written access token %d in variable %q of project %q", token.ID, accessTokenVariable, project.PathWithNamespace)
+	}
+	if err := revokeAccessToken(helper, o, old); err != nil {
+		return token, errors.Wrapf(err, "new token %d created but the old one was not revoked", token.ID)
+	}
+	return token, nil
+}
+
+func doAccessTokenRotate(cmd *cobra.Command, args []string) error {
+	if accessTokenID == 0 && accessTokenName == "" {
+		return errors.New("no access token specified: use --token-id or --name")
+	}
+	if accessTokenVariable != "" && accessTokenVariableProject == "" && accessTokenScope == "group" {
+		return errors.New("no variable project specified: use --variable-project with group tokens")
+	}
+	gitlabAPI, owners, err := selectAccessTokenOwners()
+	if err != nil {
+		return err
+	}
+	var variableProject *gitlab.Project
+	if accessTokenVariableProject != "" {
+		// every owner would overwrite the same variable with its token
+		if len(owners) > 1 {
+			return errors.Errorf("%d owners selected: --variable-project writes the token of a single owner", len(owners))
+		}
+		variableProject, err = gitlabAPI.GetProject(accessTokenVariableProject)
+		if err != nil {
+			return err
+		}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		token, err := rotateAccessToken(gitlabAPI, o, variableProject)
+		switch {
+		case err != nil && token != nil:
+			// keep the value of the new token, it can not be read again
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err), strconv.Itoa(token.ID), token.Token})
+			countNotEdit++
+		case err != nil:
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		case token == nil:
+			utils.PrintCSV([]string{o.path(), "missing"})
+			countNotEdit++
+		case accessTokenVariable != "":
+			utils.PrintCSV([]string{o.path(), "ok", strconv.Itoa(token.ID)})
+			countEdit++
+		default:
+			utils.PrintCSV([]string{o.path(), "ok", strconv.Itoa(token.ID), token.Token})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
+
+func doAccessTokenRevoke(cmd *cobra.Command, args []string) error {
+	if accessTokenID == 0 && accessTokenName == "" {
+		return errors.New("no access token specified: use --token-id or --name")
+	}
+	gitlabAPI, owners, err := selectAccessTokenOwners()
+	if err != nil {
+		return err
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		token, err := findAccessToken(gitlabAPI, o)
+		if err == nil && token != nil {
+			err = revokeAccessToken(gitlabAPI, o, token)
+		}
+		switch {
+		case err != nil:
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		case token == nil:
+			utils.PrintCSV([]string{o.path(), "missing"})
+			countNotEdit++
+		default:
+			utils.PrintCSV([]string{o.path(), "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/apex/log"
 	"github.com/janusky/gitlab-api-client/utils"
@@ -14,9 +15,9 @@ import (
 
 var (
 	username    string
-	accessLevel int
+	accessLevel string
 
-	accessLevelOptions map[int]gitlab.AccessLevelValue
+	accessLevelOptions map[string]gitlab.AccessLevelValue
 
 	addMemberProject string
 )
@@ -44,12 +45,34 @@ func init() {
 	rootCmd.AddCommand(addMemberCmd)
 	addMemberCmd.Flags().StringVarP(&addMemberProject, "project", "p", "", "The pattern to match projects")
 	addMemberCmd.Flags().StringVarP(&username, "username", "U", "", "The username to associate")
-	addMemberCmd.Flags().IntVarP(&accessLevel, "access", "L", int(gitlab.ReporterPermissions), "The access level in project (default ReporterPermissions)")
+	addMemberCmd.Flags().StringVarP(&accessLevel, "access", "L", "reporter", "The access level in project, by name or number")
 
-	accessLevelOptions = make(map[int]gitlab.AccessLevelValue)
-	accessLevelOptions[int(gitlab.ReporterPermissions)] = gitlab.ReporterPermissions
-	accessLevelOptions[int(gitlab.MasterPermissions)] = gitlab.MasterPermissions
-	accessLevelOptions[int(gitlab.OwnerPermissions)] = gitlab.OwnerPermissions
+	accessLevelOptions = make(map[string]gitlab.AccessLevelValue)
+	for _, level := range []struct {
+		name  string
+		value gitlab.AccessLevelValue
+	}{
+		{"no", gitlab.NoPermissions},
+		{"minimal", gitlab.MinimalAccessPermissions},
+		{"guest", gitlab.GuestPermissions},
+		{"reporter", gitlab.ReporterPermissions},
+		{"developer", gitlab.DeveloperPermissions},
+		{"maintainer", gitlab.MaintainerPermissions},
+		{"master", gitlab.MasterPermissions},
+		{"owner", gitlab.OwnerPermissions},
+	} {
+		accessLevelOptions[level.name] = level.value
+		accessLevelOptions[strconv.Itoa(int(level.value))] = level.value
+	}
+}
+
+// parseAccessLevel returns the access level given by name (guest, developer...) or by number
+func parseAccessLevel(level string) (gitlab.AccessLevelValue, error) {
+	value, ok := accessLevelOptions[strings.ToLower(strings.TrimSpace(level))]
+	if !ok {
+		return 0, errors.Errorf("access level value %q is not allowed", level)
+	}
+	return value, nil
 }
 
 func doAddMember(cmd *cobra.Command, args []string) error {
@@ -57,9 +80,9 @@ func doAddMember(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return errors.Wrap(err, "creating gitlab api")
 	}
-	accessLevelValue, ok := accessLevelOptions[accessLevel]
-	if !ok {
-		return errors.Wrap(err, "access level value is not allowed")
+	accessLevelValue, err := parseAccessLevel(accessLevel)
+	if err != nil {
+		return err
 	}
 	userAdd, err := gitlabAPI.GetUser(username)
 	if err != nil {
@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	archiveSelector     projectSelector
+	archiveInactiveDays int
+	archivePipelineDays int
+	archiveDryRun       bool
+	archiveYes          bool
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Archive the selected projects, or the inactive ones",
+	Long: `Archive the selected projects, or the inactive ones
+
+  With --inactive-days the projects are archived only when their last activity
+  is older than these days and they have no opened merge requests nor
+  pipelines in the last --pipeline-days.
+
+  The projects to archive are shown to confirm them, unless --yes, and the
+  plan is printed with --dry-run.`,
+	Example: `  Archive the test1 group projects without activity in a year
+
+  gitlab-api-client archive \
+    --group test1 \
+    --inactive-days 365 \
+    --dry-run \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+	RunE: doArchive,
+}
+
+var unarchiveCmd = &cobra.Command{
+	Use:   "unarchive",
+	Short: "Unarchive the selected projects",
+	RunE:  doUnarchive,
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(unarchiveCmd)
+	for _, cmd := range []*cobra.Command{archiveCmd, unarchiveCmd} {
+		archiveSelector.addFlags(cmd)
+		cmd.Flags().BoolVar(&archiveDryRun, "dry-run", false, "Print the projects without changing them")
+	}
+	archiveCmd.Flags().IntVar(&archiveInactiveDays, "inactive-days", 0, "Archive only projects without activity in these days (0 disabled)")
+	archiveCmd.Flags().IntVar(&archivePipelineDays, "pipeline-days", 0, "Keep projects with pipelines in these days (default --inactive-days)")
+	archiveCmd.Flags().BoolVarP(&archiveYes, "yes", "y", false, "Archive projects without asking for confirmation")
+}
+
+// archiveCandidate is a project of the archive plan, skipped with the skip reason
+type archiveCandidate struct {
+	project *gitlab.Project
+	skip    string
+}
+
+func (c *archiveCandidate) lastActivity() string {
+	if c.project.LastActivityAt == nil {
+		return ""
+	}
+	return c.project.LastActivityAt.Format("2006-01-02")
+}
+
+// planArchive returns the projects to archive with the skip reason of the inactive policy
+func planArchive(helper *gitlabapi.GitlabApi, projects []*gitlab.Project) ([]*archiveCandidate, error) {
+	inactiveSince := time.Now().AddDate(0, 0, -archiveInactiveDays)
+	pipelineDays := archivePipelineDays
+	if pipelineDays == 0 {
+		pipelineDays = archiveInactiveDays
+	}
+	pipelinesSince := time.Now().AddDate(0, 0, -pipelineDays)
+	plan := make([]*archiveCandidate, 0)
+	for _, project := range projects {
+		if project.Archived {
+			log.Debugf("skipped gitlab project '%s' already archived", project.PathWithNamespace)
+			continue
+		}
+		candidate := &archiveCandidate{project: project}
+		plan = append(plan, candidate)
+		if archiveInactiveDays <= 0 {
+			continue
+		}
+		if project.LastActivityAt != nil && project.LastActivityAt.After(inactiveSince) {
+			candidate.skip = "active"
+			continue
+		}
+		open, err := helper.HasOpenMergeRequests(project)
+		if err != nil {
+			return nil, err
+		}
+		if open {
+			candidate.skip = "open merge requests"
+			continue
+		}
+		recent, err := helper.HasPipelinesSince(project, pipelinesSince)
+		if err != nil {
+			return nil, err
+		}
+		if recent {
+			candidate.skip = "recent pipelines"
+		}
+	}
+	return plan, nil
+}
+
+// checkArchiveSelector rejects archiving or unarchiving every project of the instance
+func checkArchiveSelector() error {
+	if archiveSelector.group == "" && archiveSelector.project == "" {
+		return errors.New("no projects specified: use --group or --project")
+	}
+	return nil
+}
+
+func doArchive(cmd *cobra.Command, args []string) error {
+	if err := checkArchiveSelector(); err != nil {
+		return err
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := archiveSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	plan, err := planArchive(gitlabAPI, projects)
+	if err != nil {
+		return errors.Wrap(err, "planning archive")
+	}
+	archives := 0
+	for _, candidate := range plan {
+		if candidate.skip == "" {
+			archives++
+		}
+	}
+	if archiveDryRun {
+		for _, candidate := range plan {
+			action := "archive"
+			if candidate.skip != "" {
+				action = "skip " + candidate.skip
+			}
+			utils.PrintCSV([]string{candidate.project.PathWithNamespace, candidate.lastActivity(), action})
+		}
+		printTotals(len(plan), archives, len(plan)-archives)
+		return nil
+	}
+	if archives > 0 && !archiveYes {
+		for _, candidate := range plan {
+			if candidate.skip == "" {
+				fmt.Fprintf(os.Stderr, "  %s\tlast activity %s\n", candidate.project.PathWithNamespace, candidate.lastActivity())
+			}
+		}
+		ok, err := utils.Confirm(fmt.Sprintf("Archive %d projects?", archives))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("archive cancelled")
+			return nil
+		}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, candidate := range plan {
+		path := candidate.project.PathWithNamespace
+		if candidate.skip != "" {
+			utils.PrintCSV([]string{path, "skipped " + candidate.skip})
+			countNotEdit++
+			continue
+		}
+		if err := gitlabAPI.ArchiveProject(candidate.project); err != nil {
+			utils.PrintCSV([]string{path, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{path, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(plan), countEdit, countNotEdit)
+	return nil
+}
+
+func doUnarchive(cmd *cobra.Command, args []string) error {
+	if err := checkArchiveSelector(); err != nil {
+		return err
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := archiveSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	archived := make([]*gitlab.Project, 0, len(projects))
+	for _, project := range projects {
+		if !project.Archived {
+			log.Debugf("skipped gitlab project '%s' not archived", project.PathWithNamespace)
+			continue
+		}
+		archived = append(archived, project)
+	}
+	if archiveDryRun {
+		for _, project := range archived {
+			utils.PrintCSV([]string{project.PathWithNamespace, "unarchive"})
+		}
+		printTotals(len(archived), len(archived), 0)
+		return nil
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, project := range archived {
+		if err := gitlabAPI.UnarchiveProject(project); err != nil {
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{project.PathWithNamespace, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(archived), countEdit, countNotEdit)
+	return nil
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	branchesPruneSelector  projectSelector
+	branchesPruneMerged    bool
+	branchesPruneOlderThan int
+	branchesPruneExclude   []string
+	branchesPruneYes       bool
+)
+
+var branchesCmd = &cobra.Command{
+	Use:   "branches",
+	Short: "Gitlab projects branches",
+}
+
+var branchesPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove merged or stale branches",
+	Long: `Remove merged or stale branches
+
+  A branch is a candidate when it is merged (--merged) or when its last commit
+  is older than the given days (--older-than), and at least one of them is
+  required. Protected branches, default branches and branches matching any
+  --exclude pattern are never removed.`,
+	RunE: doBranchesPrune,
+	Example: `  Remove branches merged or without commits in the last 90 days
+
+  gitlab-api-client branches prune \
+    --group test1 \
+    --merged \
+    --older-than 90 \
+    --exclude '^release/' \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+}
+
+func init() {
+	rootCmd.AddCommand(branchesCmd)
+	branchesCmd.AddCommand(branchesPruneCmd)
+	branchesPruneSelector.addFlags(branchesPruneCmd)
+	branchesPruneCmd.Flags().BoolVar(&branchesPruneMerged, "merged", false, "Select branches already merged")
+	branchesPruneCmd.Flags().IntVar(&branchesPruneOlderThan, "older-than", 0, "Select branches whose last commit is older than these days (0 disabled)")
+	branchesPruneCmd.Flags().StringSliceVarP(&branchesPruneExclude, "exclude", "x", []string{}, "The patterns of branches never removed")
+	branchesPruneCmd.Flags().BoolVarP(&branchesPruneYes, "yes", "y", false, "Remove branches without asking for confirmation")
+}
+
+type pruneCandidate struct {
+	project *gitlab.Project
+	branch  *gitlab.Branch
+	reason  string
+}
+
+func (c *pruneCandidate) committedDate() string {
+	if c.branch.Commit == nil || c.branch.Commit.CommittedDate == nil {
+		return ""
+	}
+	return c.branch.Commit.CommittedDate.Format("2006/01/02")
+}
+
+// pruneReason returns why the branch must be removed, or empty when it must be kept
+func pruneReason(project *gitlab.Project, branch *gitlab.Branch, excludes []*regexp.Regexp, limit time.Time) string {
+	if branch.Protected || branch.Default || branch.Name == project.DefaultBranch {
+		return ""
+	}
+	for _, exclude := range excludes {
+		if exclude.MatchString(branch.Name) {
+			return ""
+		}
+	}
+	if branchesPruneMerged && branch.Merged {
+		return "merged"
+	}
+	if branchesPruneOlderThan > 0 && branch.Commit != nil && branch.Commit.CommittedDate != nil && branch.Commit.CommittedDate.Before(limit) {
+		return "stale"
+	}
+	return ""
+}
+
+func doBranchesPrune(cmd *cobra.Command, args []string) error {
+	if !branchesPruneMerged && branchesPruneOlderThan <= 0 {
+		return errors.New("nothing to prune: use --merged or --older-than")
+	}
+	excludes := make([]*regexp.Regexp, 0)
+	for _, pattern := range branchesPruneExclude {
+		exclude, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "compiling exclude pattern '%s'", pattern)
+		}
+		excludes = append(excludes, exclude)
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := branchesPruneSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	limit := time.Now().AddDate(0, 0, -branchesPruneOlderThan)
+	candidates := make([]*pruneCandidate, 0)
+	for _, project := range projects {
+		branches, err := gitlabAPI.ListProjectBranches(project)
+		if err != nil {
+			return errors.Wrapf(err, "listing branches of project %q", project.PathWithNamespace)
+		}
+		for _, branch := range branches {
+			reason := pruneReason(project, branch, excludes, limit)
+			if reason == "" {
+				log.Debugf("kept gitlab project '%s' branch '%s'", project.Name, branch.Name)
+				continue
+			}
+			candidates = append(candidates, &pruneCandidate{project: project, branch: branch, reason: reason})
+		}
+	}
+	if len(candidates) == 0 {
+		log.Info("no branches to prune")
+		return nil
+	}
+	for _, c := range candidates {
+		utils.PrintCSV([]string{c.project.PathWithNamespace, c.branch.Name, c.reason, c.committedDate()})
+	}
+	if !branchesPruneYes {
+		ok, err := utils.Confirm(fmt.Sprintf("Remove %d branches?", len(candidates)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("prune cancelled")
+			return nil
+		}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, c := range candidates {
+		err := gitlabAPI.DeleteBranch(c.project, c.branch.Name)
+		if err != nil {
+			utils.PrintCSV([]string{c.project.PathWithNamespace, c.branch.Name, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{c.project.PathWithNamespace, c.branch.Name, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(candidates), countEdit, countNotEdit)
+	return nil
+}
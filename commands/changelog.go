@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	changelogSelector      projectSelector
+	changelogFrom          string
+	changelogTo            string
+	changelogOutput        string
+	changelogFeatureLabels []string
+	changelogFixLabels     []string
+	changelogChoreLabels   []string
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate a Markdown changelog between two refs",
+	Long: `Generate a Markdown changelog between two refs
+
+  The merged merge requests and the commits between --from and --to are
+  collected with the compare API (default the latest two tags). Merge requests
+  are grouped by their labels, and the remaining ones and commits by their
+  conventional commit prefix (feat, fix, chore...).`,
+	RunE: doChangelog,
+	Example: `  Changelog of the latest release of project test1-app
+
+  gitlab-api-client changelog \
+    --project '^test1-app$' \
+    --output CHANGELOG.md \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Changelog between two tags, used as release notes
+
+  gitlab-api-client changelog -p '^test1-app$' --from v1.1.0 --to v1.2.0 > NOTES.md
+  gitlab-api-client release create -p '^test1-app$' --tag v1.2.0 --notes-file NOTES.md`,
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogSelector.addFlags(changelogCmd)
+	changelogCmd.Flags().StringVar(&changelogFrom, "from", "", "The starting ref (default the previous tag)")
+	changelogCmd.Flags().StringVar(&changelogTo, "to", "", "The ending ref (default the latest tag)")
+	changelogCmd.Flags().StringVarP(&changelogOutput, "output", "o", "", "The output file (default Stdout)")
+	changelogCmd.Flags().StringSliceVar(&changelogFeatureLabels, "feature-labels", []string{"feature", "enhancement"}, "The labels of feature merge requests")
+	changelogCmd.Flags().StringSliceVar(&changelogFixLabels, "fix-labels", []string{"fix", "bug"}, "The labels of fix merge requests")
+	changelogCmd.Flags().StringSliceVar(&changelogChoreLabels, "chore-labels", []string{"chore", "maintenance"}, "The labels of chore merge requests")
+}
+
+var changelogSections = []struct {
+	kind  string
+	title string
+}{
+	{"feature", "Features"},
+	{"fix", "Fixes"},
+	{"chore", "Chores"},
+	{"other", "Other changes"},
+}
+
+var conventionalCommitRegexp = regexp.MustCompile(`^(\w+)(\([^)]*\))?!?:`)
+
+// changeKind returns the changelog section of a change from its labels or its title prefix
+func changeKind(labels []string, title string) string {
+	for _, label := range labels {
+		switch {
+		case containsFold(changelogFeatureLabels, label):
+			return "feature"
+		case containsFold(changelogFixLabels, label):
+			return "fix"
+		case containsFold(changelogChoreLabels, label):
+			return "chore"
+		}
+	}
+	m := conventionalCommitRegexp.FindStringSubmatch(title)
+	if m == nil {
+		return "other"
+	}
+	switch strings.ToLower(m[1]) {
+	case "feat", "feature":
+		return "feature"
+	case "fix", "bugfix", "hotfix":
+		return "fix"
+	case "chore", "build", "ci", "docs", "refactor", "style", "test", "perf":
+		return "chore"
+	}
+	return "other"
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// latestTags returns the names of the previous and the latest project tags
+func latestTags(helper *gitlabapi.GitlabApi, project *gitlab.Project) (string, string, error) {
+	tags, err := helper.ListProjectTags(project)
+	if err != nil {
+		return "", "", err
+	}
+	if len(tags) < 2 {
+		return "", "", errors.Errorf("project %q needs two tags to compare (found %d)", project.PathWithNamespace, len(tags))
+	}
+	return tags[1].Name, tags[0].Name, nil
+}
+
+func writeChangelog(w io.Writer, project *gitlab.Project, changes *gitlabapi.Changes) {
+	lines := make(map[string][]string)
+	for _, mr := range changes.MergeRequests {
+		kind := changeKind(mr.Labels, mr.Title)
+		lines[kind] = append(lines[kind], fmt.Sprintf("- %s (!%d)", mr.Title, mr.IID))
+	}
+	for _, commit := range changes.Commits {
+		kind := changeKind(nil, commit.Title)
+		lines[kind] = append(lines[kind], fmt.Sprintf("- %s (%s)", commit.Title, commit.ShortID))
+	}
+	fmt.Fprintf(w, "## %s %s\n\n", project.PathWithNamespace, changes.To)
+	fmt.Fprintf(w, "Changes since %s.\n\n", changes.From)
+	for _, section := range changelogSections {
+		if len(lines[section.kind]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "### %s\n\n", section.title)
+		for _, line := range lines[section.kind] {
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func doChangelog(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := changelogSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	var out io.Writer = os.Stdout
+	if changelogOutput != "" {
+		f, err := os.Create(changelogOutput)
+		if err != nil {
+			return errors.Wrapf(err, "creating output file %q", changelogOutput)
+		}
+		defer f.Close()
+		out = f
+	}
+	for _, project := range projects {
+		from, to := changelogFrom, changelogTo
+		if from == "" || to == "" {
+			previous, latest, err := latestTags(gitlabAPI, project)
+			if err != nil {
+				log.Warnf("skipped project '%s': %v", project.PathWithNamespace, err)
+				continue
+			}
+			if from == "" {
+				from = previous
+			}
+			if to == "" {
+				to = latest
+			}
+		}
+		changes, err := gitlabAPI.ListChanges(project, from, to)
+		if err != nil {
+			log.Warnf("skipped project '%s': listing changes: %v", project.PathWithNamespace, err)
+			continue
+		}
+		writeChangelog(out, project, changes)
+	}
+	return nil
+}
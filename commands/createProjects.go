@@ -4,13 +4,15 @@ import (
 	"encoding/csv"
 	"io"
 	"os"
-	"sort"
-	"unicode"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/janusky/gitlab-api-client/utils"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 var createProjectsCmd = &cobra.Command{
@@ -18,39 +20,180 @@ var createProjectsCmd = &cobra.Command{
 	Short:   "Create one or more gitlab projects",
 	Aliases: []string{"create-project"},
 	RunE:    doCreateProjects,
+	Long: `Create one or more gitlab projects
+
+  The --from file has CSV rows (group,project) and optionally these columns,
+  overriding the flags when not empty:
+
+    group,project,visibility,description,default_branch,readme,topics,
+    template,ci_config_path,merge_method,owner_access
+
+  where readme is true or false and topics are separated by spaces.
+
+  The group is a full path, like platform/infra/tools, and every missing group
+  of the path is created under its parent with --group-visibility. Without
+  group the projects are created in the current user namespace or, as admin,
+  in the --user namespace.`,
 	Example: `  Creation of projects in *grupo* and with users *owner*
 
   gitlab-api-client create-project test1-app test1-db  \
     --group test1 \
     --owners user1,userN \
-    --api-url https://gitlab.localhost/api/v4/ \	
+    --api-url https://gitlab.localhost/api/v4/ \
     --private-token token \
-    --trusted-certificates @certificates.pem`,
+    --trusted-certificates @certificates.pem
+
+  Creation of private projects with README and fast forward merges
+
+  gitlab-api-client create-project test1-lib \
+    --group platform/infra/test1 \
+    --visibility private \
+    --group-visibility internal \
+    --readme \
+    --default-branch main \
+    --merge-method ff \
+    --owners user1 \
+    --owner-access developer
+
+  Creation of a project in the user1 personal namespace, as admin
+
+  gitlab-api-client create-project sandbox --user user1`,
 }
 
 var (
-	createProjectsGroup  string
-	createProjectsOwners []string
-	createProjectsFrom   string
+	createProjectsGroup           string
+	createProjectsOwners          []string
+	createProjectsFrom            string
+	createProjectsVisibility      string
+	createProjectsDescription     string
+	createProjectsBranch          string
+	createProjectsReadme          bool
+	createProjectsTopics          []string
+	createProjectsTemplate        string
+	createProjectsCIConfigPath    string
+	createProjectsMergeMethod     string
+	createProjectsOwnerAccess     string
+	createProjectsNamePattern     string
+	createProjectsGroupVisibility string
+	createProjectsUser            string
 )
 
 func init() {
 	rootCmd.AddCommand(createProjectsCmd)
-	createProjectsCmd.Flags().StringVarP(&createProjectsGroup, "group", "g", "", "The group where projects will be created")
+	createProjectsCmd.Flags().StringVarP(&createProjectsGroup, "group", "g", "", "The group where projects will be created (the user namespace when empty)")
+	createProjectsCmd.Flags().StringVar(&createProjectsUser, "user", "", "The user whose namespace projects will be created in without group, as admin (default the current user)")
 	createProjectsCmd.Flags().StringSliceVarP(&createProjectsOwners, "owners", "o", []string{}, "The owners of the new projects")
-	createProjectsCmd.Flags().StringVarP(&createProjectsFrom, "from", "f", "", "CSV input file (group,project) to read groups and projects from")
+	createProjectsCmd.Flags().StringVarP(&createProjectsFrom, "from", "f", "", "CSV input file (group,project,...) to read groups and projects from")
+	createProjectsCmd.Flags().StringVar(&createProjectsVisibility, "visibility", "public", "The visibility of the new projects (private, internal or public)")
+	createProjectsCmd.Flags().StringVar(&createProjectsGroupVisibility, "group-visibility", "", "The visibility of the new groups (default --visibility)")
+	createProjectsCmd.Flags().StringVar(&createProjectsDescription, "description", "", "The description of the new projects")
+	createProjectsCmd.Flags().StringVar(&createProjectsBranch, "default-branch", "", "The default branch of the new projects")
+	createProjectsCmd.Flags().BoolVar(&createProjectsReadme, "readme", false, "Initialize the new projects with a README")
+	createProjectsCmd.Flags().StringSliceVar(&createProjectsTopics, "topics", []string{}, "The topics of the new projects")
+	createProjectsCmd.Flags().StringVar(&createProjectsTemplate, "template", "", "The built-in template name of the new projects")
+	createProjectsCmd.Flags().StringVar(&createProjectsCIConfigPath, "ci-config-path", "", "The CI configuration file path of the new projects")
+	createProjectsCmd.Flags().StringVar(&createProjectsMergeMethod, "merge-method", "", "The merge method of the new projects (merge, rebase_merge or ff)")
+	createProjectsCmd.Flags().StringVar(&createProjectsOwnerAccess, "owner-access", "maintainer", "The access level of the owners in the new projects, by name or number")
+	createProjectsCmd.Flags().StringVar(&createProjectsNamePattern, "name-pattern", `^[\p{Ll}\p{Nd}-]+$`, "The pattern group and project names must match")
+}
+
+func validateName(pattern *regexp.Regexp, arg string) error {
+	if !pattern.MatchString(arg) {
+		return errors.Errorf("name %q not matching '%s'", arg, pattern)
+	}
+	return nil
 }
 
-func validateName(arg string) error {
-	for _, char := range arg {
-		if !unicode.In(char, unicode.Digit, unicode.Lower) && char != '-' {
-			return errors.Errorf("character %q not allowed in name %q (must be all lower or '-')", char, arg)
+// validateGroupPath validates every group name of the full path
+func validateGroupPath(pattern *regexp.Regexp, path string) error {
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if err := validateName(pattern, segment); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+func parseVisibility(visibility string) (gitlab.VisibilityValue, error) {
+	switch v := gitlab.VisibilityValue(visibility); v {
+	case gitlab.PrivateVisibility, gitlab.InternalVisibility, gitlab.PublicVisibility:
+		return v, nil
+	}
+	return "", errors.Errorf("unknown visibility: %s (private, internal or public)", visibility)
+}
+
+func parseMergeMethod(method string) (gitlab.MergeMethodValue, error) {
+	switch m := gitlab.MergeMethodValue(method); m {
+	case "", gitlab.NoFastForwardMerge, gitlab.RebaseMerge, gitlab.FastForwardMerge:
+		return m, nil
+	}
+	return "", errors.Errorf("unknown merge method: %s (merge, rebase_merge or ff)", method)
+}
+
+// projectSpec returns the spec of the project from the flags and the --from columns
+// (visibility,description,default_branch,readme,topics,template,ci_config_path,merge_method,owner_access)
+func projectSpec(name string, columns []string) (*gitlabapi.ProjectSpec, error) {
+	values := []string{
+		createProjectsVisibility,
+		createProjectsDescription,
+		createProjectsBranch,
+		strconv.FormatBool(createProjectsReadme),
+		strings.Join(createProjectsTopics, " "),
+		createProjectsTemplate,
+		createProjectsCIConfigPath,
+		createProjectsMergeMethod,
+		createProjectsOwnerAccess,
+	}
+	for i, column := range columns {
+		if i < len(values) && strings.TrimSpace(column) != "" {
+			values[i] = strings.TrimSpace(column)
+		}
+	}
+	visibility, err := parseVisibility(values[0])
+	if err != nil {
+		return nil, err
+	}
+	readme, err := strconv.ParseBool(values[3])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing readme %q", values[3])
+	}
+	mergeMethod, err := parseMergeMethod(values[7])
+	if err != nil {
+		return nil, err
+	}
+	ownerAccess, err := parseAccessLevel(values[8])
+	if err != nil {
+		return nil, err
+	}
+	return &gitlabapi.ProjectSpec{
+		Name:                 name,
+		Visibility:           visibility,
+		Description:          values[1],
+		DefaultBranch:        values[2],
+		InitializeWithReadme: readme,
+		Topics:               strings.Fields(values[4]),
+		TemplateName:         values[5],
+		CIConfigPath:         values[6],
+		MergeMethod:          mergeMethod,
+		OwnerAccess:          ownerAccess,
+	}, nil
+}
+
 func doCreateProjects(cmd *cobra.Command, args []string) error {
+	if createProjectsGroup != "" && createProjectsUser != "" {
+		return errors.New("--user is the namespace without group: do not use it with --group")
+	}
+	namePattern, err := regexp.Compile(createProjectsNamePattern)
+	if err != nil {
+		return errors.Wrapf(err, "compiling name pattern '%s'", createProjectsNamePattern)
+	}
+	if createProjectsGroupVisibility == "" {
+		createProjectsGroupVisibility = createProjectsVisibility
+	}
+	groupVisibility, err := parseVisibility(createProjectsGroupVisibility)
+	if err != nil {
+		return err
+	}
 	helper, err := gitlabAPI()
 	if err != nil {
 		return errors.Wrap(err, "creating gitlab helper")
@@ -61,8 +204,10 @@ func doCreateProjects(cmd *cobra.Command, args []string) error {
 			return errors.Wrapf(err, "opening input file %q", createProjectsFrom)
 		}
 		defer f.Close()
-		groups := make(map[string][]string)
+		groupNames := make([]string, 0)
+		groups := make(map[string][]*gitlabapi.ProjectSpec)
 		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
 		for {
 			rec, err := r.Read()
 			if err == io.EOF {
@@ -73,41 +218,79 @@ func doCreateProjects(cmd *cobra.Command, args []string) error {
 			if err != nil {
 				return errors.Wrapf(err, "parsing csv data from file %q", createProjectsFrom)
 			}
-			g := rec[0]
-			err = validateName(g)
-			if err != nil {
-				return errors.Wrap(err, "invalid group name")
+			if len(rec) < 2 {
+				return errors.Errorf("missing project in csv row %v from file %q", rec, createProjectsFrom)
+			}
+			g := strings.Trim(rec[0], "/")
+			if g != "" {
+				err = validateGroupPath(namePattern, g)
+				if err != nil {
+					return errors.Wrap(err, "invalid group name")
+				}
 			}
 			p := rec[1]
-			err = validateName(p)
+			err = validateName(namePattern, p)
 			if err != nil {
 				return errors.Wrap(err, "invalid project name")
 			}
-			ps := groups[g]
-			i := sort.SearchStrings(ps, p)
-			log.Infof("g=%s i=%d ps=%+v", g, i, ps)
-			switch {
-			case i >= len(ps):
-				groups[g] = append(ps, p)
-			case i < len(ps) && ps[i] != p:
-				groups[g] = append(ps[:i], append([]string{p}, ps[i:]...)...)
+			spec, err := projectSpec(p, rec[2:])
+			if err != nil {
+				return errors.Wrapf(err, "invalid project %q settings", p)
+			}
+			ps, ok := groups[g]
+			if !ok {
+				groupNames = append(groupNames, g)
+			}
+			duplicated := false
+			for _, s := range ps {
+				duplicated = duplicated || s.Name == p
+			}
+			if duplicated {
+				log.Warnf("skipped duplicated project %q in group %q", p, g)
+				continue
 			}
-			log.Infof("groups=%+v", groups)
+			groups[g] = append(ps, spec)
 		}
-		log.Infof("groups are %+v", groups)
-		for group, projects := range groups {
-			log.Infof("creating projects %v in group %v with owners %v", projects, group, createProjectsOwners)
-			err := helper.CreateProjects(createProjectsOwners, group, projects)
+		for _, group := range groupNames {
+			projects := groups[group]
+			if group == "" {
+				log.Infof("creating %d projects of user %q with owners %v", len(projects), createProjectsUser, createProjectsOwners)
+				err := helper.CreateUserProjects(createProjectsOwners, createProjectsUser, projects)
+				if err != nil {
+					return errors.Wrap(err, "creating user projects")
+				}
+				continue
+			}
+			log.Infof("creating %d projects in group %v with owners %v", len(projects), group, createProjectsOwners)
+			err := helper.CreateProjects(createProjectsOwners, group, groupVisibility, projects)
 			if err != nil {
-				return errors.Wrapf(err, "creating projects %v in group %q", projects, group)
+				return errors.Wrapf(err, "creating projects in group %q", group)
 			}
 		}
 	}
 	if len(args) > 0 {
+		projects := make([]*gitlabapi.ProjectSpec, 0, len(args))
+		for _, name := range args {
+			if err := validateName(namePattern, name); err != nil {
+				return errors.Wrap(err, "invalid project name")
+			}
+			spec, err := projectSpec(name, nil)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, spec)
+		}
 		if createProjectsGroup == "" {
-			return utils.NotImplementedError("user projects creation")
+			err := helper.CreateUserProjects(createProjectsOwners, createProjectsUser, projects)
+			if err != nil {
+				return errors.Wrap(err, "creating user projects")
+			}
+			return nil
+		}
+		if err := validateGroupPath(namePattern, createProjectsGroup); err != nil {
+			return errors.Wrap(err, "invalid group name")
 		}
-		err := helper.CreateProjects(createProjectsOwners, createProjectsGroup, args)
+		err := helper.CreateProjects(createProjectsOwners, createProjectsGroup, groupVisibility, projects)
 		if err != nil {
 			return errors.Wrap(err, "creating projects")
 		}
@@ -2,81 +2,271 @@ package commands
 
 import (
 	"fmt"
-	"strconv"
+	"io/ioutil"
+	"strings"
 
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
 	"github.com/janusky/gitlab-api-client/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 var (
-	deployKeyID        int
-	deployKeyProjectID int
-	deployKeyDisabled  bool
+	deployKeySelector    projectSelector
+	deployKeyID          int
+	deployKeyFingerprint string
+	deployKeyProjectID   int
+	deployKeyFile        string
+	deployKeyTitle       string
+	deployKeyCanPush     bool
 )
 
 var deployKeyCmd = &cobra.Command{
 	Use:     "deploy-key",
 	Short:   "Gitlab projects deploy key",
 	Aliases: []string{"deploy-key-projects"},
-	RunE:    doDeployKeyProjects,
-	Example: `  Operating gitlab projects deploy key (disabled|enabled)
-	
-  # Enabled deploy-key
-  gitlab-api-client deploy-key \
+	Example: `  Operating gitlab projects deploy key (create|enable|disable|update)
+
+  # Create deploy-key from a public key file in group test1 projects
+  gitlab-api-client deploy-key create \
+    --group test1 \
+    --key-file ~/.ssh/deploy.pub \
+    --title deploy \
     --api-url https://gitlab.localhost/api/v4/ \
     --private-token token \
     --trusted-certificates @certificates.pem
 
-  # Disabled deploy-key
-  gitlab-api-client deploy-key --disabled \
-    --api-url https://gitlab.localhost/api/v4/ \
-    --private-token token \
-    --trusted-certificates @certificates.pem`,
+  # Enable existing deploy-key
+  gitlab-api-client deploy-key enable --group test1 --key-id 12
+
+  # Disable deploy-key by fingerprint
+  gitlab-api-client deploy-key disable --group test1 \
+    --fingerprint SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8`,
+}
+
+var deployKeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a deploy key from a public key file and enable it in the projects",
+	RunE:  doDeployKeyCreate,
+}
+
+var deployKeyEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable an existing deploy key, by id or fingerprint, in the projects",
+	Long: `Enable an existing deploy key, by id or fingerprint, in the projects
+
+  A key given by fingerprint is searched in the --project-id project keys, or
+  in all the instance keys when it is not given (admin only).`,
+	RunE: doDeployKeyEnable,
+}
+
+var deployKeyDisableCmd = &cobra.Command{
+	Use:     "disable",
+	Short:   "Disable a deploy key, by id or fingerprint, in the projects",
+	Aliases: []string{"disabled"},
+	RunE:    doDeployKeyDisable,
+}
+
+var deployKeyUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the title or can_push of a deploy key, by id or fingerprint, in the projects",
+	RunE:  doDeployKeyUpdate,
 }
 
 func init() {
 	rootCmd.AddCommand(deployKeyCmd)
-	deployKeyCmd.Flags().BoolVarP(&deployKeyDisabled, "disabled", "d", false, "The project to disabled or enabled deploy key")
-	deployKeyCmd.Flags().IntVarP(&deployKeyProjectID, "project-id", "q", 0, "The project to be searched for keys")
-	deployKeyCmd.Flags().IntVarP(&deployKeyID, "key-id", "k", 0, "Id deploy key")
+	for _, cmd := range []*cobra.Command{deployKeyCreateCmd, deployKeyEnableCmd, deployKeyDisableCmd, deployKeyUpdateCmd} {
+		deployKeyCmd.AddCommand(cmd)
+		deployKeySelector.addFlags(cmd)
+	}
+	for _, cmd := range []*cobra.Command{deployKeyEnableCmd, deployKeyDisableCmd, deployKeyUpdateCmd} {
+		cmd.Flags().IntVarP(&deployKeyID, "key-id", "k", 0, "Id deploy key")
+		cmd.Flags().StringVar(&deployKeyFingerprint, "fingerprint", "", "Fingerprint deploy key (MD5 or SHA256)")
+	}
+	deployKeyEnableCmd.Flags().IntVarP(&deployKeyProjectID, "project-id", "q", 0, "The project to be searched for keys")
+	deployKeyCreateCmd.Flags().StringVarP(&deployKeyFile, "key-file", "f", "", "The SSH public key file")
+	deployKeyCreateCmd.MarkFlagRequired("key-file")
+	for _, cmd := range []*cobra.Command{deployKeyCreateCmd, deployKeyUpdateCmd} {
+		cmd.Flags().StringVar(&deployKeyTitle, "title", "", "The deploy key title")
+		cmd.Flags().BoolVar(&deployKeyCanPush, "can-push", false, "The deploy key can push")
+	}
 }
 
-func doDeployKeyProjects(cmd *cobra.Command, args []string) error {
+func validateDeployKeyFlags() error {
+	if deployKeyID == 0 && deployKeyFingerprint == "" {
+		return errors.New("no deploy key specified: use --key-id or --fingerprint")
+	}
+	return nil
+}
+
+// eachDeployKeyProject runs the operation on every selected project reporting the result as add-member does
+func eachDeployKeyProject(helper *gitlabapi.GitlabApi, operation func(*gitlab.Project) (string, error)) error {
+	projects, err := deployKeySelector.selectProjects(helper)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, project := range projects {
+		status, err := operation(project)
+		switch {
+		case err != nil:
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		case status == "exists" || status == "missing":
+			utils.PrintCSV([]string{project.PathWithNamespace, status})
+			countNotEdit++
+		default:
+			utils.PrintCSV([]string{project.PathWithNamespace, status})
+			countEdit++
+		}
+	}
+	printTotals(len(projects), countEdit, countNotEdit)
+	return nil
+}
+
+func doDeployKeyCreate(cmd *cobra.Command, args []string) error {
+	bs, err := ioutil.ReadFile(deployKeyFile)
+	if err != nil {
+		return errors.Wrapf(err, "reading key file %q", deployKeyFile)
+	}
+	key := strings.TrimSpace(string(bs))
+	pub, err := utils.ParseSSHPublicKey(key)
+	if err != nil {
+		return errors.Wrapf(err, "parsing key file %q", deployKeyFile)
+	}
+	title := deployKeyTitle
+	if title == "" {
+		title = pub.Comment
+	}
+	if title == "" {
+		return errors.New("no title specified and the key has no comment")
+	}
 	gitlabAPI, err := gitlabAPI()
 	if err != nil {
 		return errors.Wrap(err, "creating gitlab api")
 	}
-	projects, err := gitlabAPI.ListAllGroupsProjects()
+	var created *gitlab.DeployKey
+	return eachDeployKeyProject(gitlabAPI, func(project *gitlab.Project) (string, error) {
+		existing, err := gitlabAPI.FindProjectDeployKey(project, 0, pub.FingerprintSHA256())
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			// the next projects enable the existing key instead of adding it again
+			if created == nil {
+				created = existing
+			}
+			return "exists", nil
+		}
+		if created != nil {
+			return "ok", gitlabAPI.EnableProjectDeployKey(project, created.ID)
+		}
+		created, err = gitlabAPI.AddDeployKey(project, &gitlab.AddDeployKeyOptions{
+			Title:   &title,
+			Key:     &key,
+			CanPush: &deployKeyCanPush,
+		})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("created %d", created.ID), nil
+	})
+}
+
+// resolveDeployKeyID returns the id of the key given by id or fingerprint
+func resolveDeployKeyID(helper *gitlabapi.GitlabApi) (int, error) {
+	if deployKeyFingerprint == "" {
+		return deployKeyID, nil
+	}
+	var keys []*gitlab.DeployKey
+	var err error
+	if deployKeyProjectID != 0 {
+		keys, err = helper.ListDeployKeys(deployKeyProjectID)
+	} else {
+		keys, err = helper.ListAllDeployKeys()
+	}
 	if err != nil {
-		return errors.Wrap(err, "listing projects")
+		return 0, err
+	}
+	key := gitlabapi.FindDeployKey(keys, 0, deployKeyFingerprint)
+	if key == nil {
+		return 0, errors.Errorf("deploy key with fingerprint %q not found", deployKeyFingerprint)
 	}
-	deployKey, err := gitlabAPI.GetDeployKey(deployKeyProjectID, deployKeyID)
+	return key.ID, nil
+}
+
+func doDeployKeyEnable(cmd *cobra.Command, args []string) error {
+	if err := validateDeployKeyFlags(); err != nil {
+		return err
+	}
+	gitlabAPI, err := gitlabAPI()
 	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	id, err := resolveDeployKeyID(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "resolving deploy key")
+	}
+	return eachDeployKeyProject(gitlabAPI, func(project *gitlab.Project) (string, error) {
+		existing, err := gitlabAPI.FindProjectDeployKey(project, id, "")
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			return "exists", nil
+		}
+		return "ok", gitlabAPI.EnableProjectDeployKey(project, id)
+	})
+}
+
+func doDeployKeyDisable(cmd *cobra.Command, args []string) error {
+	if err := validateDeployKeyFlags(); err != nil {
 		return err
 	}
-	countEdit := 0
-	countNotEdit := 0
-	for _, project := range projects {
-		if project.Public {
-			if deployKeyDisabled {
-				err = gitlabAPI.DisabledDeployKey(deployKey, project.ID)
-			} else {
-				err = gitlabAPI.EnableDeployKey(deployKey, project.ID)
-			}
-			if err != nil {
-				utils.PrintCSV([]string{project.Name, fmt.Sprintf("Fail %v", err)})
-				countNotEdit++
-			} else {
-				utils.PrintCSV([]string{project.Name, "ok"})
-				countEdit++
-			}
-		} else {
-			countNotEdit++
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	return eachDeployKeyProject(gitlabAPI, func(project *gitlab.Project) (string, error) {
+		key, err := gitlabAPI.FindProjectDeployKey(project, deployKeyID, deployKeyFingerprint)
+		if err != nil {
+			return "", err
+		}
+		if key == nil {
+			return "missing", nil
 		}
+		return "ok", gitlabAPI.DisableProjectDeployKey(project, key)
+	})
+}
+
+func doDeployKeyUpdate(cmd *cobra.Command, args []string) error {
+	if err := validateDeployKeyFlags(); err != nil {
+		return err
 	}
-	utils.PrintCSV([]string{"total", strconv.Itoa(len(projects))})
-	utils.PrintCSV([]string{"edit", strconv.Itoa(countEdit)})
-	utils.PrintCSV([]string{"notEdit", strconv.Itoa(countNotEdit)})
-	return nil
+	opts := &gitlab.UpdateDeployKeyOptions{}
+	if cmd.Flags().Changed("title") {
+		opts.Title = &deployKeyTitle
+	}
+	if cmd.Flags().Changed("can-push") {
+		opts.CanPush = &deployKeyCanPush
+	}
+	if opts.Title == nil && opts.CanPush == nil {
+		return errors.New("nothing to update: use --title or --can-push")
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	return eachDeployKeyProject(gitlabAPI, func(project *gitlab.Project) (string, error) {
+		key, err := gitlabAPI.FindProjectDeployKey(project, deployKeyID, deployKeyFingerprint)
+		if err != nil {
+			return "", err
+		}
+		if key == nil {
+			return "missing", nil
+		}
+		return "ok", gitlabAPI.UpdateProjectDeployKey(project, key, opts)
+	})
 }
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deployKeyAuditSelector     projectSelector
+	deployKeyAuditFormat       string
+	deployKeyAuditMinRSABits   int
+	deployKeyAuditOnlyFindings bool
+)
+
+var deployKeyAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Report the deploy keys of the projects and their weaknesses",
+	Long: `Report the deploy keys of the projects and their weaknesses
+
+  Every key is reported once, by fingerprint, with the projects where it is
+  enabled (rw when it can push, ro otherwise) and these findings:
+
+    weak-algorithm   DSA keys or RSA keys under --min-rsa-bits
+    single-project   keys enabled in only one project
+    push-protected   keys that can push to projects with protected branches
+    duplicate-title  keys sharing the title with other keys`,
+	RunE: doDeployKeyAudit,
+	Example: `  Audit every project deploy keys
+
+  gitlab-api-client deploy-key audit \
+    --format csv \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+}
+
+func init() {
+	deployKeyCmd.AddCommand(deployKeyAuditCmd)
+	deployKeyAuditSelector.addFlags(deployKeyAuditCmd)
+	deployKeyAuditCmd.Flags().StringVarP(&deployKeyAuditFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+	addProfilesFlag(deployKeyAuditCmd)
+	deployKeyAuditCmd.Flags().IntVar(&deployKeyAuditMinRSABits, "min-rsa-bits", 3072, "The minimum size of RSA keys")
+	deployKeyAuditCmd.Flags().BoolVar(&deployKeyAuditOnlyFindings, "only-findings", false, "Report only the keys with findings")
+}
+
+type deployKeyAccess struct {
+	Project string `json:"project"`
+	CanPush bool   `json:"can_push"`
+}
+
+type deployKeyAudit struct {
+	Fingerprint string             `json:"fingerprint"`
+	Type        string             `json:"type"`
+	Bits        int                `json:"bits"`
+	Titles      []string           `json:"titles"`
+	Projects    []*deployKeyAccess `json:"projects"`
+	Findings    []string           `json:"findings"`
+}
+
+func (a *deployKeyAudit) projects() []string {
+	projects := make([]string, 0)
+	for _, access := range a.Projects {
+		mode := "ro"
+		if access.CanPush {
+			mode = "rw"
+		}
+		projects = append(projects, fmt.Sprintf("%s(%s)", access.Project, mode))
+	}
+	return projects
+}
+
+func (a *deployKeyAudit) CSV() []string {
+	return []string{a.Fingerprint, a.Type, strconv.Itoa(a.Bits), strings.Join(a.Titles, " "), strings.Join(a.projects(), " "), strings.Join(a.Findings, " ")}
+}
+
+func (a *deployKeyAudit) Plain() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%s:%s", a.Fingerprint, a.Type, a.Bits, strings.Join(a.Titles, ","), strings.Join(a.projects(), ","), strings.Join(a.Findings, ","))
+}
+
+func addUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func doDeployKeyAudit(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := deployKeyAuditSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	audits := make(map[string]*deployKeyAudit)
+	pushProtected := make(map[string]bool)
+	for _, project := range projects {
+		keys, err := gitlabAPI.ListDeployKeys(project.ID)
+		if err != nil {
+			return errors.Wrapf(err, "listing project %q deploy keys", project.PathWithNamespace)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		protected, err := gitlabAPI.ListProtectedBranches(project)
+		if err != nil {
+			return errors.Wrapf(err, "listing project %q protected branches", project.PathWithNamespace)
+		}
+		for _, key := range keys {
+			pub, err := utils.ParseSSHPublicKey(key.Key)
+			if err != nil {
+				log.Warnf("skipped project '%s' deploy key %d: %v", project.PathWithNamespace, key.ID, err)
+				continue
+			}
+			fingerprint := pub.FingerprintSHA256()
+			audit, ok := audits[fingerprint]
+			if !ok {
+				bits, err := pub.Bits()
+				if err != nil {
+					log.Warnf("deploy key %s: %v", fingerprint, err)
+				}
+				audit = &deployKeyAudit{Fingerprint: fingerprint, Type: pub.Type, Bits: bits, Findings: make([]string, 0)}
+				audits[fingerprint] = audit
+			}
+			canPush := key.CanPush != nil && *key.CanPush
+			audit.Titles = addUnique(audit.Titles, key.Title)
+			audit.Projects = append(audit.Projects, &deployKeyAccess{Project: project.PathWithNamespace, CanPush: canPush})
+			if canPush && len(protected) > 0 {
+				pushProtected[fingerprint] = true
+			}
+		}
+	}
+	titles := make(map[string]int)
+	for _, audit := range audits {
+		for _, title := range audit.Titles {
+			titles[title]++
+		}
+	}
+	fingerprints := make([]string, 0, len(audits))
+	for fingerprint, audit := range audits {
+		fingerprints = append(fingerprints, fingerprint)
+		if audit.Type == "ssh-dss" || (audit.Type == "ssh-rsa" && audit.Bits < deployKeyAuditMinRSABits) {
+			audit.Findings = append(audit.Findings, "weak-algorithm")
+		}
+		if len(audit.Projects) == 1 {
+			audit.Findings = append(audit.Findings, "single-project")
+		}
+		if pushProtected[fingerprint] {
+			audit.Findings = append(audit.Findings, "push-protected")
+		}
+		for _, title := range audit.Titles {
+			if titles[title] > 1 {
+				audit.Findings = append(audit.Findings, "duplicate-title")
+				break
+			}
+		}
+	}
+	sort.Strings(fingerprints)
+	for _, fingerprint := range fingerprints {
+		audit := audits[fingerprint]
+		if deployKeyAuditOnlyFindings && len(audit.Findings) == 0 {
+			continue
+		}
+		if err := printRecord(deployKeyAuditFormat, audit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	deployTokenSelector  projectSelector
+	deployTokenScope     string
+	deployTokenFormat    string
+	deployTokenName      string
+	deployTokenID        int
+	deployTokenUsername  string
+	deployTokenScopes    []string
+	deployTokenExpiresAt string
+	deployTokenDays      int
+)
+
+var deployTokenCmd = &cobra.Command{
+	Use:   "deploy-token",
+	Short: "Gitlab projects and groups deploy tokens",
+	Example: `  Create a registry pull token in group test1 projects
+
+  gitlab-api-client deploy-token create \
+    --group test1 \
+    --name registry \
+    --scopes read_registry \
+    --expires-at 2021-12-31 \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Report the group deploy tokens expiring in the next 30 days
+
+  gitlab-api-client deploy-token expiring --scope group --days 30`,
+}
+
+var deployTokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the deploy tokens of projects or groups",
+	RunE:  doDeployTokenList,
+}
+
+var deployTokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a deploy token in projects or groups",
+	Long: `Create a deploy token in projects or groups
+
+  The token values are only shown once, in the report of the command.`,
+	RunE: doDeployTokenCreate,
+}
+
+var deployTokenRevokeCmd = &cobra.Command{
+	Use:     "revoke",
+	Short:   "Revoke a deploy token, by id or name, in projects or groups",
+	Aliases: []string{"delete"},
+	RunE:    doDeployTokenRevoke,
+}
+
+var deployTokenExpiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "Report the deploy tokens expiring within some days, without the expired ones",
+	RunE:  doDeployTokenExpiring,
+}
+
+func init() {
+	rootCmd.AddCommand(deployTokenCmd)
+	for _, cmd := range []*cobra.Command{deployTokenListCmd, deployTokenCreateCmd, deployTokenRevokeCmd, deployTokenExpiringCmd} {
+		deployTokenCmd.AddCommand(cmd)
+		deployTokenSelector.addFlags(cmd)
+		cmd.Flags().StringVar(&deployTokenScope, "scope", "project", "The tokens owner (project or group)")
+	}
+	for _, cmd := range []*cobra.Command{deployTokenListCmd, deployTokenExpiringCmd} {
+		cmd.Flags().StringVarP(&deployTokenFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+		addProfilesFlag(cmd)
+	}
+	for _, cmd := range []*cobra.Command{deployTokenCreateCmd, deployTokenRevokeCmd} {
+		cmd.Flags().StringVarP(&deployTokenName, "name", "n", "", "The deploy token name")
+	}
+	deployTokenRevokeCmd.Flags().IntVar(&deployTokenID, "token-id", 0, "The deploy token id")
+	deployTokenCreateCmd.MarkFlagRequired("name")
+	deployTokenCreateCmd.Flags().StringVarP(&deployTokenUsername, "username", "U", "", "The deploy token username (default gitlab+deploy-token-{n})")
+	deployTokenCreateCmd.Flags().StringSliceVar(&deployTokenScopes, "scopes", []string{"read_registry"}, "The deploy token scopes (read_repository, read_registry, write_registry, read_package_registry, write_package_registry)")
+	deployTokenCreateCmd.Flags().StringVar(&deployTokenExpiresAt, "expires-at", "", "The deploy token expiration date (YYYY-MM-DD, never when empty)")
+	deployTokenExpiringCmd.Flags().IntVar(&deployTokenDays, "days", 30, "The days to report the tokens expiring within")
+}
+
+type deployTokenRecord struct {
+	Scope     string   `json:"scope"`
+	Path      string   `json:"path"`
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Username  string   `json:"username"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+func newDeployTokenRecord(o *owner, token *gitlab.DeployToken) *deployTokenRecord {
+	expiresAt := ""
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.Format("2006-01-02")
+	}
+	return &deployTokenRecord{
+		Scope:     o.scope(),
+		Path:      o.path(),
+		ID:        token.ID,
+		Name:      token.Name,
+		Username:  token.Username,
+		Scopes:    token.Scopes,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (r *deployTokenRecord) CSV() []string {
+	return []string{r.Scope, r.Path, strconv.Itoa(r.ID), r.Name, r.Username, strings.Join(r.Scopes, " "), r.ExpiresAt}
+}
+
+func (r *deployTokenRecord) Plain() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%s:%s:%s", r.Scope, r.Path, r.ID, r.Name, r.Username, strings.Join(r.Scopes, ","), r.ExpiresAt)
+}
+
+func listDeployTokens(helper *gitlabapi.GitlabApi, o *owner) ([]*gitlab.DeployToken, error) {
+	if o.group != nil {
+		return helper.ListGroupDeployTokens(o.group)
+	}
+	return helper.ListProjectDeployTokens(o.project)
+}
+
+// printDeployTokens prints the tokens of the selected owners accepted by the filter
+func printDeployTokens(filter func(*gitlab.DeployToken) bool) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := deployTokenSelector.selectOwners(gitlabAPI, deployTokenScope)
+	if err != nil {
+		return errors.Wrap(err, "selecting owners")
+	}
+	for _, o := range owners {
+		tokens, err := listDeployTokens(gitlabAPI, o)
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if !filter(token) {
+				continue
+			}
+			if err := printRecord(deployTokenFormat, newDeployTokenRecord(o, token)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func doDeployTokenList(cmd *cobra.Command, args []string) error {
+	return printDeployTokens(func(*gitlab.DeployToken) bool {
+		return true
+	})
+}
+
+func doDeployTokenExpiring(cmd *cobra.Command, args []string) error {
+	now := time.Now()
+	limit := now.AddDate(0, 0, deployTokenDays)
+	return printDeployTokens(func(token *gitlab.DeployToken) bool {
+		// the expired tokens are still listed, but they do not expire anymore
+		return token.ExpiresAt != nil && token.ExpiresAt.After(now) && token.ExpiresAt.Before(limit)
+	})
+}
+
+func doDeployTokenCreate(cmd *cobra.Command, args []string) error {
+	var expiresAt *time.Time
+	if deployTokenExpiresAt != "" {
+		t, err := time.Parse("2006-01-02", deployTokenExpiresAt)
+		if err != nil {
+			return errors.Wrapf(err, "parsing expiration date %q", deployTokenExpiresAt)
+		}
+		expiresAt = &t
+	}
+	opts := &gitlab.CreateProjectDeployTokenOptions{
+		Name:      &deployTokenName,
+		ExpiresAt: expiresAt,
+		Scopes:    deployTokenScopes,
+	}
+	if deployTokenUsername != "" {
+		opts.Username = &deployTokenUsername
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := deployTokenSelector.selectOwners(gitlabAPI, deployTokenScope)
+	if err != nil {
+		return errors.Wrap(err, "selecting owners")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		var token *gitlab.DeployToken
+		if o.group != nil {
+			groupOpts := gitlab.CreateGroupDeployTokenOptions(*opts)
+			token, err = gitlabAPI.CreateGroupDeployToken(o.group, &groupOpts)
+		} else {
+			token, err = gitlabAPI.CreateProjectDeployToken(o.project, opts)
+		}
+		if err != nil {
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{o.path(), "ok", token.Username, token.Token})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
+
+func doDeployTokenRevoke(cmd *cobra.Command, args []string) error {
+	if deployTokenID == 0 && deployTokenName == "" {
+		return errors.New("no deploy token specified: use --token-id or --name")
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := deployTokenSelector.selectOwners(gitlabAPI, deployTokenScope)
+	if err != nil {
+		return errors.Wrap(err, "selecting owners")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		tokens, err := listDeployTokens(gitlabAPI, o)
+		if err != nil {
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+			continue
+		}
+		revoked := 0
+		for _, token := range tokens {
+			if (deployTokenID != 0 && token.ID != deployTokenID) || (deployTokenName != "" && token.Name != deployTokenName) {
+				continue
+			}
+			if o.group != nil {
+				err = gitlabAPI.DeleteGroupDeployToken(o.group, token)
+			} else {
+				err = gitlabAPI.DeleteProjectDeployToken(o.project, token)
+			}
+			if err != nil {
+				break
+			}
+			revoked++
+		}
+		switch {
+		case err != nil:
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		case revoked == 0:
+			utils.PrintCSV([]string{o.path(), "missing"})
+			countNotEdit++
+		default:
+			utils.PrintCSV([]string{o.path(), "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	exportSelector projectSelector
+	exportDir      string
+	exportTimeout  time.Duration
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the selected projects to files to import them in another instance",
+	Long: `Export the selected projects to files to import them in another instance
+
+  The export of every project is scheduled, waited for up to --timeout and
+  downloaded to --dir, where the state file (migration.json) records the
+  exported projects and their direct members, which the import command maps
+  by username in the target instance.
+
+  The exported projects are skipped when the command is run again, so an
+  interrupted export is resumed.`,
+	Example: `  Export the test1 group projects to the exports directory
+
+  gitlab-api-client export \
+    --group test1 \
+    --dir exports \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+	RunE: doExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportSelector.addFlags(exportCmd)
+	exportCmd.Flags().StringVar(&exportDir, "dir", "exports", "The directory where the exports and the state file are saved")
+	exportCmd.Flags().DurationVar(&exportTimeout, "timeout", 10*time.Minute, "The time to wait for each project export")
+}
+
+// migrationStateFile is the state file name of export and import directories
+const migrationStateFile = "migration.json"
+
+// migrationMember is a project member to map by username in the target instance
+type migrationMember struct {
+	Username    string                  `json:"username"`
+	AccessLevel gitlab.AccessLevelValue `json:"access_level"`
+	ExpiresAt   string                  `json:"expires_at,omitempty"`
+}
+
+// migrationProject is the export and import state of a project
+type migrationProject struct {
+	Path         string             `json:"path"`
+	File         string             `json:"file"`
+	Members      []*migrationMember `json:"members"`
+	ImportID     int                `json:"import_id,omitempty"`
+	ImportedPath string             `json:"imported_path,omitempty"`
+	ImportStatus string             `json:"import_status,omitempty"`
+}
+
+// migrationState is the state file of an export directory, saved after every step
+type migrationState struct {
+	dir      string
+	Projects []*migrationProject `json:"projects"`
+}
+
+func readMigrationState(dir string) (*migrationState, error) {
+	state := &migrationState{dir: dir, Projects: make([]*migrationProject, 0)}
+	file := filepath.Join(dir, migrationStateFile)
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading state file %q", file)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrapf(err, "parsing state file %q", file)
+	}
+	return state, nil
+}
+
+// save writes the state file through a temporary file, to keep the previous one on failures
+func (s *migrationState) save() error {
+	file := filepath.Join(s.dir, migrationStateFile)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding state")
+	}
+	if err := ioutil.WriteFile(file+".tmp", data, 0600); err != nil {
+		return errors.Wrapf(err, "writing state file %q", file)
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return errors.Wrapf(err, "writing state file %q", file)
+	}
+	return nil
+}
+
+func (s *migrationState) find(path string) *migrationProject {
+	for _, p := range s.Projects {
+		if p.Path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// exportProject saves the project export and direct members in the state directory
+func exportProject(helper *gitlabapi.GitlabApi, state *migrationState, project *gitlab.Project) error {
+	members, err := helper.ListProjectMembers(project, false)
+	if err != nil {
+		return err
+	}
+	data, err := helper.ExportProject(project, exportTimeout)
+	if err != nil {
+		return err
+	}
+	name := strings.Replace(project.PathWithNamespace, "/", "_", -1) + "-export.tar.gz"
+	if err := ioutil.WriteFile(filepath.Join(state.dir, name), data, 0600); err != nil {
+		return errors.Wrapf(err, "writing export file %q", name)
+	}
+	exported := &migrationProject{Path: project.PathWithNamespace, File: name, Members: make([]*migrationMember, 0, len(members))}
+	for _, member := range members {
+		m := &migrationMember{Username: member.Username, AccessLevel: member.AccessLevel}
+		if member.ExpiresAt != nil {
+			m.ExpiresAt = member.ExpiresAt.String()
+		}
+		exported.Members = append(exported.Members, m)
+	}
+	state.Projects = append(state.Projects, exported)
+	log.Infof("saved project '%s' export in %s", project.PathWithNamespace, name)
+	return state.save()
+}
+
+func doExport(cmd *cobra.Command, args []string) error {
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return errors.Wrapf(err, "creating directory %q", exportDir)
+	}
+	state, err := readMigrationState(exportDir)
+	if err != nil {
+		return err
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := exportSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, project := range projects {
+		if exported := state.find(project.PathWithNamespace); exported != nil {
+			if _, err := os.Stat(filepath.Join(exportDir, exported.File)); err == nil {
+				utils.PrintCSV([]string{project.PathWithNamespace, "exists"})
+				countNotEdit++
+				continue
+			}
+			log.Warnf("exported file %s of project '%s' not found, exporting it again", exported.File, project.PathWithNamespace)
+			state.Projects = removeMigrationProject(state.Projects, exported)
+		}
+		if err := exportProject(gitlabAPI, state, project); err != nil {
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{project.PathWithNamespace, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(projects), countEdit, countNotEdit)
+	return nil
+}
+
+func removeMigrationProject(projects []*migrationProject, removed *migrationProject) []*migrationProject {
+	kept := make([]*migrationProject, 0, len(projects))
+	for _, p := range projects {
+		if p != removed {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
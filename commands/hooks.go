@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	hooksSelector projectSelector
+	hooksFormat   string
+	hooksURL      string
+	hooksNewURL   string
+	hooksToken    string
+	hooksBranch   string
+	hooksEvents   = map[string]*bool{}
+	hooksSSL      bool
+)
+
+// hookEvents are the event toggle flags and their descriptions
+var hookEvents = []struct {
+	flag  string
+	usage string
+}{
+	{"push-events", "Trigger hook on push events"},
+	{"tag-push-events", "Trigger hook on tag push events"},
+	{"merge-requests-events", "Trigger hook on merge requests events"},
+	{"issues-events", "Trigger hook on issues events"},
+	{"confidential-issues-events", "Trigger hook on confidential issues events"},
+	{"note-events", "Trigger hook on note events"},
+	{"confidential-note-events", "Trigger hook on confidential note events"},
+	{"job-events", "Trigger hook on job events"},
+	{"pipeline-events", "Trigger hook on pipeline events"},
+	{"wiki-page-events", "Trigger hook on wiki page events"},
+	{"deployment-events", "Trigger hook on deployment events"},
+}
+
+var hooksCmd = &cobra.Command{
+	Use:     "hooks",
+	Short:   "Gitlab projects webhooks",
+	Aliases: []string{"hook"},
+}
+
+var hooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the webhooks of gitlab projects",
+	RunE:  doHooksList,
+}
+
+var hooksAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a webhook to gitlab projects",
+	RunE:  doHooksAdd,
+	Example: `  Add a webhook for push and merge requests events to group test1 projects
+
+  gitlab-api-client hooks add \
+    --group test1 \
+    --url https://ci.localhost/hook \
+    --token secret \
+    --push-events --merge-requests-events \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+}
+
+var hooksUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the webhooks with the url in gitlab projects",
+	Long: `Update the webhooks with the url in gitlab projects
+
+  Only the flags given are changed, the url is changed with --new-url.`,
+	RunE: doHooksUpdate,
+}
+
+var hooksRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove the webhooks with the url from gitlab projects",
+	Aliases: []string{"delete"},
+	RunE:    doHooksRemove,
+}
+
+var hooksSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Add or update the webhook with the url in gitlab projects",
+	Long: `Add or update the webhook with the url in gitlab projects
+
+  The hooks are matched by url: projects without it get a new hook and
+  projects with it get the hook updated with the given settings.`,
+	RunE: doHooksSync,
+}
+
+func addHookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&hooksToken, "token", "", "The secret token to validate received payloads")
+	cmd.Flags().StringVar(&hooksBranch, "push-events-branch-filter", "", "The branches triggering push events")
+	cmd.Flags().BoolVar(&hooksSSL, "enable-ssl-verification", true, "Do SSL verification when triggering the hook")
+	for _, event := range hookEvents {
+		if _, ok := hooksEvents[event.flag]; !ok {
+			hooksEvents[event.flag] = new(bool)
+		}
+		cmd.Flags().BoolVar(hooksEvents[event.flag], event.flag, false, event.usage)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	for _, cmd := range []*cobra.Command{hooksListCmd, hooksAddCmd, hooksUpdateCmd, hooksRemoveCmd, hooksSyncCmd} {
+		hooksCmd.AddCommand(cmd)
+		hooksSelector.addFlags(cmd)
+	}
+	hooksListCmd.Flags().StringVarP(&hooksFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+	addProfilesFlag(hooksListCmd)
+	hooksListCmd.Flags().StringVar(&hooksURL, "url", "", "The url of the hooks listed (all when empty)")
+	for _, cmd := range []*cobra.Command{hooksAddCmd, hooksUpdateCmd, hooksRemoveCmd, hooksSyncCmd} {
+		cmd.Flags().StringVar(&hooksURL, "url", "", "The hook url")
+		cmd.MarkFlagRequired("url")
+	}
+	for _, cmd := range []*cobra.Command{hooksAddCmd, hooksUpdateCmd, hooksSyncCmd} {
+		addHookFlags(cmd)
+	}
+	hooksUpdateCmd.Flags().StringVar(&hooksNewURL, "new-url", "", "The new hook url")
+}
+
+// hookOptions builds the hook options, with all the settings or only the flags changed
+func hookOptions(cmd *cobra.Command, changedOnly bool) *gitlab.AddProjectHookOptions {
+	set := func(name string) bool {
+		return !changedOnly || cmd.Flags().Changed(name)
+	}
+	opts := &gitlab.AddProjectHookOptions{URL: gitlab.String(hooksURL)}
+	if set("token") {
+		opts.Token = gitlab.String(hooksToken)
+	}
+	if set("push-events-branch-filter") {
+		opts.PushEventsBranchFilter = gitlab.String(hooksBranch)
+	}
+	if set("enable-ssl-verification") {
+		opts.EnableSSLVerification = gitlab.Bool(hooksSSL)
+	}
+	events := map[string]**bool{
+		"push-events":                &opts.PushEvents,
+		"tag-push-events":            &opts.TagPushEvents,
+		"merge-requests-events":      &opts.MergeRequestsEvents,
+		"issues-events":              &opts.IssuesEvents,
+		"confidential-issues-events": &opts.ConfidentialIssuesEvents,
+		"note-events":                &opts.NoteEvents,
+		"confidential-note-events":   &opts.ConfidentialNoteEvents,
+		"job-events":                 &opts.JobEvents,
+		"pipeline-events":            &opts.PipelineEvents,
+		"wiki-page-events":           &opts.WikiPageEvents,
+		"deployment-events":          &opts.DeploymentEvents,
+	}
+	for flag, field := range events {
+		if set(flag) {
+			*field = gitlab.Bool(*hooksEvents[flag])
+		}
+	}
+	return opts
+}
+
+type hookRecord struct {
+	Project string   `json:"project"`
+	ID      int      `json:"id"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	SSL     bool     `json:"enable_ssl_verification"`
+}
+
+func newHookRecord(project *gitlab.Project, hook *gitlab.ProjectHook) *hookRecord {
+	enabled := map[string]bool{
+		"push":                hook.PushEvents,
+		"tag_push":            hook.TagPushEvents,
+		"merge_requests":      hook.MergeRequestsEvents,
+		"issues":              hook.IssuesEvents,
+		"confidential_issues": hook.ConfidentialIssuesEvents,
+		"note":                hook.NoteEvents,
+		"confidential_note":   hook.ConfidentialNoteEvents,
+		"job":                 hook.JobEvents,
+		"pipeline":            hook.PipelineEvents,
+		"wiki_page":           hook.WikiPageEvents,
+		"deployment":          hook.DeploymentEvents,
+	}
+	events := make([]string, 0)
+	for _, event := range hookEvents {
+		name := strings.Replace(strings.TrimSuffix(event.flag, "-events"), "-", "_", -1)
+		if enabled[name] {
+			events = append(events, name)
+		}
+	}
+	return &hookRecord{
+		Project: project.PathWithNamespace,
+		ID:      hook.ID,
+		URL:     hook.URL,
+		Events:  events,
+		SSL:     hook.EnableSSLVerification,
+	}
+}
+
+func (r *hookRecord) CSV() []string {
+	return []string{r.Project, strconv.Itoa(r.ID), r.URL, strings.Join(r.Events, " "), strconv.FormatBool(r.SSL)}
+}
+
+func (r *hookRecord) Plain() string {
+	return fmt.Sprintf("%s:%d:%s:%s:%t", r.Project, r.ID, r.URL, strings.Join(r.Events, ","), r.SSL)
+}
+
+func doHooksList(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := hooksSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	for _, project := range projects {
+		hooks, err := gitlabAPI.ListProjectHooks(project)
+		if err != nil {
+			return err
+		}
+		for _, hook := range hooks {
+			if hooksURL != "" && hook.URL != hooksURL {
+				continue
+			}
+			if err := printRecord(hooksFormat, newHookRecord(project, hook)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eachHookProject runs the operation on every selected project reporting the result as add-member does
+func eachHookProject(operation func(*gitlabapi.GitlabApi, *gitlab.Project) (string, error)) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, err := hooksSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, project := range projects {
+		status, err := operation(gitlabAPI, project)
+		switch {
+		case err != nil:
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		case status == "":
+			countNotEdit++
+		default:
+			utils.PrintCSV([]string{project.PathWithNamespace, status})
+			countEdit++
+		}
+	}
+	printTotals(len(projects), countEdit, countNotEdit)
+	return nil
+}
+
+func doHooksAdd(cmd *cobra.Command, args []string) error {
+	opts := hookOptions(cmd, false)
+	return eachHookProject(func(helper *gitlabapi.GitlabApi, project *gitlab.Project) (string, error) {
+		hooks, err := helper.FindProjectHooks(project, hooksURL)
+		if err != nil {
+			return "", err
+		}
+		if len(hooks) > 0 {
+			return "", errors.Errorf("hook %d already exists", hooks[0].ID)
+		}
+		_, err = helper.AddProjectHook(project, opts)
+		if err != nil {
+			return "", err
+		}
+		return "ok", nil
+	})
+}
+
+func doHooksUpdate(cmd *cobra.Command, args []string) error {
+	opts := gitlab.EditProjectHookOptions(*hookOptions(cmd, true))
+	if hooksNewURL != "" {
+		opts.URL = gitlab.String(hooksNewURL)
+	}
+	return eachHookProject(func(helper *gitlabapi.GitlabApi, project *gitlab.Project) (string, error) {
+		hooks, err := helper.FindProjectHooks(project, hooksURL)
+		if err != nil || len(hooks) == 0 {
+			return "", err
+		}
+		for _, hook := range hooks {
+			_, err := helper.EditProjectHook(project, hook, &opts)
+			if err != nil {
+				return "", err
+			}
+		}
+		return "ok", nil
+	})
+}
+
+func doHooksRemove(cmd *cobra.Command, args []string) error {
+	return eachHookProject(func(helper *gitlabapi.GitlabApi, project *gitlab.Project) (string, error) {
+		hooks, err := helper.FindProjectHooks(project, hooksURL)
+		if err != nil || len(hooks) == 0 {
+			return "", err
+		}
+		for _, hook := range hooks {
+			err := helper.DeleteProjectHook(project, hook)
+			if err != nil {
+				return "", err
+			}
+		}
+		return "ok", nil
+	})
+}
+
+func doHooksSync(cmd *cobra.Command, args []string) error {
+	opts := hookOptions(cmd, false)
+	return eachHookProject(func(helper *gitlabapi.GitlabApi, project *gitlab.Project) (string, error) {
+		return helper.SyncProjectHook(project, opts)
+	})
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	importDir       string
+	importNamespace string
+	importTimeout   time.Duration
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import the projects exported by the export command",
+	Long: `Import the projects exported by the export command
+
+  The exported files of --dir are uploaded to the configured instance, usually
+  another profile than the one of the export, in the --namespace group or in
+  the namespace they had when exported. Every import is waited for up to
+  --timeout and then the exported members are added by username, with the
+  exported access level, when the user exists in this instance.
+
+  The state file of --dir records the imports, so an interrupted import is
+  resumed, a failed import is uploaded again and the imported projects are
+  skipped when the command is run again.`,
+	Example: `  Import the projects of the exports directory in the platform/legacy group
+  of the instance of the target profile
+
+  gitlab-api-client import \
+    --dir exports \
+    --namespace platform/legacy \
+    --profile target`,
+	RunE: doImport,
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importDir, "dir", "exports", "The directory of the exports and the state file")
+	importCmd.Flags().StringVar(&importNamespace, "namespace", "", "The group full path the projects are imported in (default the exported namespace)")
+	importCmd.Flags().DurationVar(&importTimeout, "timeout", 10*time.Minute, "The time to wait for each project import")
+}
+
+func (p *migrationProject) target() string {
+	namespace := importNamespace
+	if namespace == "" {
+		namespace = path.Dir(p.Path)
+	}
+	return strings.Trim(namespace, "/") + "/" + path.Base(p.Path)
+}
+
+// mapMembers adds the exported members missing in the imported project and returns
+// the usernames not found in this instance
+func mapMembers(helper *gitlabapi.GitlabApi, project *gitlab.Project, members []*migrationMember) ([]string, error) {
+	current, err := helper.ListProjectMembers(project, false)
+	if err != nil {
+		return nil, err
+	}
+	unmapped := make([]string, 0)
+	for _, m := range members {
+		if findMember(current, m.Username) != nil {
+			continue
+		}
+		user, err := helper.GetUser(m.Username)
+		if err != nil {
+			log.Warnf("member '%s' of project '%s' not mapped: %v", m.Username, project.PathWithNamespace, err)
+			unmapped = append(unmapped, m.Username)
+			continue
+		}
+		if err := helper.AddProjectMember(project, user, m.AccessLevel, optionalExpiresAt(m.ExpiresAt)); err != nil {
+			return nil, err
+		}
+	}
+	return unmapped, nil
+}
+
+func optionalExpiresAt(expiresAt string) *string {
+	if expiresAt == "" {
+		return nil
+	}
+	return &expiresAt
+}
+
+// importProject uploads the exported project, or resumes the started import, and
+// maps its members
+func importProject(helper *gitlabapi.GitlabApi, state *migrationState, p *migrationProject) ([]string, error) {
+	target := p.target()
+	if p.ImportID == 0 {
+		existing, err := helper.FindProject(target)
+		if err != nil {
+			return nil, err
+		}
+		// the project of a failed import is overwritten by the new upload
+		overwrite := existing != nil && existing.ImportStatus == "failed"
+		if existing != nil && !overwrite {
+			return nil, errors.Errorf("project %q already exists", target)
+		}
+		p.ImportID, err = helper.ImportProject(filepath.Join(state.dir, p.File), path.Dir(target), path.Base(target), overwrite)
+		if err != nil {
+			return nil, err
+		}
+		p.ImportStatus = "started"
+		if err := state.save(); err != nil {
+			return nil, err
+		}
+	}
+	project, err := helper.WaitImport(p.ImportID, importTimeout)
+	if _, failed := errors.Cause(err).(*gitlabapi.ImportFailedError); failed {
+		// the next run uploads the project again
+		p.ImportID = 0
+		p.ImportStatus = ""
+		if err := state.save(); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	unmapped, err := mapMembers(helper, project, p.Members)
+	if err != nil {
+		return nil, err
+	}
+	p.ImportedPath = project.PathWithNamespace
+	p.ImportStatus = "finished"
+	return unmapped, state.save()
+}
+
+func doImport(cmd *cobra.Command, args []string) error {
+	state, err := readMigrationState(importDir)
+	if err != nil {
+		return err
+	}
+	if len(state.Projects) == 0 {
+		return errors.Errorf("no exported projects in %q", importDir)
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, p := range state.Projects {
+		if p.ImportStatus == "finished" {
+			utils.PrintCSV([]string{p.Path, p.ImportedPath, "exists"})
+			countNotEdit++
+			continue
+		}
+		unmapped, err := importProject(gitlabAPI, state, p)
+		if err != nil {
+			utils.PrintCSV([]string{p.Path, p.target(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+			continue
+		}
+		status := "ok"
+		if len(unmapped) > 0 {
+			status = "ok, unmapped members " + strings.Join(unmapped, " ")
+		}
+		utils.PrintCSV([]string{p.Path, p.ImportedPath, status})
+		countEdit++
+	}
+	printTotals(len(state.Projects), countEdit, countNotEdit)
+	return nil
+}
@@ -1,13 +1,11 @@
 package commands
 
 import (
-	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/apex/log"
-	"github.com/janusky/gitlab-api-client/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -34,6 +32,21 @@ func init() {
 	rootCmd.AddCommand(listGroupsCmd)
 	listGroupsCmd.Flags().StringVarP(&listGroup, "group", "g", "", "The pattern to match groups")
 	listGroupsCmd.Flags().StringVarP(&listGroupFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+	addProfilesFlag(listGroupsCmd)
+}
+
+// groupRecord is a group of the groups listing
+type groupRecord struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (r *groupRecord) CSV() []string {
+	return []string{r.ID, r.Name}
+}
+
+func (r *groupRecord) Plain() string {
+	return r.ID + ":" + r.Name
 }
 
 func doListGroups(cmd *cobra.Command, args []string) error {
@@ -54,15 +67,8 @@ func doListGroups(cmd *cobra.Command, args []string) error {
 			log.Debugf("skipped gitlab group '%s' not matching '%s'", group.Name, listGroup)
 			continue
 		}
-		switch listGroupFormat {
-		case "csv":
-			utils.PrintCSV([]string{strconv.Itoa(group.ID), group.Name})
-		case "plain":
-			utils.PrintPlain(fmt.Sprintf("%d:%s", group.ID, group.Name))
-		case "json":
-			utils.PrintJSON(&map[string]string{"id": strconv.Itoa(group.ID), "name": group.Name})
-		default:
-			return errors.Errorf("unknown list format: %s", listGroupFormat)
+		if err := printRecord(listGroupFormat, &groupRecord{ID: strconv.Itoa(group.ID), Name: group.Name}); err != nil {
+			return err
 		}
 	}
 	return nil
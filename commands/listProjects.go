@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/apex/log"
-	"github.com/janusky/gitlab-api-client/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -36,6 +35,32 @@ func init() {
 	listProjectsCmd.Flags().StringVarP(&listProjectGroup, "group", "g", "", "The pattern to match groups")
 	listProjectsCmd.Flags().StringVarP(&listProject, "project", "p", "", "The pattern to match projects")
 	listProjectsCmd.Flags().StringVarP(&listProjectFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+	addProfilesFlag(listProjectsCmd)
+}
+
+// projectRecord is a project of the projects listing
+type projectRecord struct {
+	Created    string `json:"created"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Visibility string `json:"visibility"`
+}
+
+func newProjectRecord(project *gitlab.Project) *projectRecord {
+	return &projectRecord{
+		Created:    fmt.Sprintf("%d/%02d/%02d", project.CreatedAt.Year(), project.CreatedAt.Month(), project.CreatedAt.Day()),
+		ID:         strconv.Itoa(project.ID),
+		Name:       project.PathWithNamespace,
+		Visibility: fmt.Sprintf("%v", project.Visibility),
+	}
+}
+
+func (r *projectRecord) CSV() []string {
+	return []string{r.Visibility, r.Name, r.ID, r.Created}
+}
+
+func (r *projectRecord) Plain() string {
+	return strings.Join(r.CSV(), ":")
 }
 
 func doListProjects(cmd *cobra.Command, args []string) error {
@@ -68,18 +93,8 @@ func doListProjects(cmd *cobra.Command, args []string) error {
 				log.Debugf("skipped gitlab project '%s' not matching '%s'", project.Name, listProject)
 				continue
 			}
-			visibility := fmt.Sprintf("%v", project.Visibility)
-			createdAtFmt := fmt.Sprintf("%d/%02d/%02d", project.CreatedAt.Year(), project.CreatedAt.Month(), project.CreatedAt.Day())
-
-			switch listProjectFormat {
-			case "csv":
-				utils.PrintCSV([]string{visibility, project.PathWithNamespace, strconv.Itoa(project.ID), createdAtFmt})
-			case "plain":
-				utils.PrintPlain(fmt.Sprintf("%s:%s:%d:%s", visibility, project.PathWithNamespace, project.ID, createdAtFmt))
-			case "json":
-				utils.PrintJSON(&map[string]string{"visibility": visibility, "name": project.PathWithNamespace, "id": strconv.Itoa(project.ID), "created": createdAtFmt})
-			default:
-				return errors.Errorf("unknown list format: %s", listProjectFormat)
+			if err := printRecord(listProjectFormat, newProjectRecord(project)); err != nil {
+				return err
 			}
 		}
 	}
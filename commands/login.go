@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var logoutAll bool
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Save the token of the instance in the encrypted token store",
+	Long: `Save the token of the instance in the encrypted token store
+
+  The token is asked, checked with the instance and saved encrypted with a
+  passphrase in the token store, by the profile in use or by the api url
+  without profile. The passphrase is asked, or taken from the
+  GITLAB_API_CLIENT_PASSPHRASE environment variable, when the token is used by
+  the commands without any other token setting.
+
+  The token sources are, in this order, --private-token (or the private-token
+  setting), --private-token-file, --credential-helper, the token store and the
+  CI_JOB_TOKEN environment variable of the Gitlab CI jobs. The --token-type
+  oauth authenticates with the token as an OAuth2 bearer token.`,
+	Example: `  Save the token of the staging profile
+
+  gitlab-api-client login --profile staging
+
+  Use a git credential helper to get the token
+
+  gitlab-api-client list-projects --credential-helper 'git credential-store'`,
+	RunE: doLogin,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the token of the instance from the encrypted token store",
+	RunE:  doLogout,
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "Remove the token store with the tokens of every instance")
+}
+
+func doLogin(cmd *cobra.Command, args []string) error {
+	httpClient, err := httpClient()
+	if err != nil {
+		return err
+	}
+	apiURL := viper.GetString(settingKey("api-url", gitlabAPIURL))
+	token, err := utils.ReadSecret("Token of " + apiURL + ": ")
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("empty token")
+	}
+	helper, err := gitlabapi.NewGitlabApiToken(httpClient, apiURL, token, viper.GetString(settingKey("token-type", gitlabTokenType)))
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	user, err := helper.CurrentUser()
+	if err != nil {
+		return errors.Wrap(err, "checking token")
+	}
+	store, err := openTokenStore()
+	if err != nil {
+		return err
+	}
+	if err := unlockTokenStore(store, len(store.Tokens) == 0); err != nil {
+		return err
+	}
+	// the tokens of a store are encrypted with the same passphrase
+	for name := range store.Tokens {
+		if _, err := store.Get(name); err != nil {
+			return err
+		}
+		break
+	}
+	name := tokenStoreName(apiURL)
+	if err := store.Set(name, token); err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+	log.Infof("logged in %s as '%s'", name, user.Username)
+	return nil
+}
+
+func doLogout(cmd *cobra.Command, args []string) error {
+	store, err := openTokenStore()
+	if err != nil {
+		return err
+	}
+	if logoutAll {
+		if err := os.Remove(store.File()); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "removing token store")
+		}
+		return nil
+	}
+	name := tokenStoreName(viper.GetString(settingKey("api-url", gitlabAPIURL)))
+	if !store.Has(name) {
+		log.Infof("no token of %s in the token store", name)
+		return nil
+	}
+	store.Delete(name)
+	if err := store.Save(); err != nil {
+		return err
+	}
+	log.Infof("logged out %s", name)
+	return nil
+}
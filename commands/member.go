@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	memberSelector  projectSelector
+	memberScope     string
+	memberFormat    string
+	memberUsername  string
+	memberAll       bool
+	memberAccess    string
+	memberExpiresAt string
+	memberOwners    []string
+)
+
+var memberCmd = &cobra.Command{
+	Use:   "member",
+	Short: "Gitlab projects and groups members",
+	Long: `Gitlab projects and groups members
+
+  The access levels are given by name or by number:
+
+    no (0), minimal (5), guest (10), reporter (20), developer (30),
+    maintainer or master (40) and owner (50) - only valid for groups`,
+	Example: `  List the members, inherited too, of test1 group projects
+
+  gitlab-api-client member list \
+    --group test1 \
+    --all \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Set the user1 access to developer in test1 group
+
+  gitlab-api-client member update --scope group --group test1 --username user1 --access developer
+
+  Expire the user1 membership of test1 group projects
+
+  gitlab-api-client member expire --group test1 --username user1 --expires-at 2021-12-31
+
+  Make user1 and user2 the only direct owners of test1 group projects
+
+  gitlab-api-client member replace-owners --group test1 --owners user1,user2`,
+}
+
+var memberListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the members of projects or groups",
+	RunE:  doMemberList,
+}
+
+var memberUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update the access level of a member of projects or groups",
+	RunE:  doMemberUpdate,
+}
+
+var memberExpireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Set the expiration date of a member of projects or groups",
+	RunE:  doMemberExpire,
+}
+
+var memberRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Remove a member of projects or groups",
+	Aliases: []string{"delete"},
+	RunE:    doMemberRemove,
+}
+
+var memberReplaceOwnersCmd = &cobra.Command{
+	Use:   "replace-owners",
+	Short: "Make the users the only direct owners of projects",
+	Long: `Make the users the only direct owners of projects
+
+  The users missing in the project are added as owners, the members are
+  promoted to owners and the other direct owners are removed.`,
+	RunE: doMemberReplaceOwners,
+}
+
+func init() {
+	rootCmd.AddCommand(memberCmd)
+	for _, cmd := range []*cobra.Command{memberListCmd, memberUpdateCmd, memberExpireCmd, memberRemoveCmd} {
+		memberCmd.AddCommand(cmd)
+		memberSelector.addFlags(cmd)
+		cmd.Flags().StringVar(&memberScope, "scope", "project", "The members owner (project or group)")
+		cmd.Flags().StringVarP(&memberUsername, "username", "U", "", "The member username")
+	}
+	for _, cmd := range []*cobra.Command{memberUpdateCmd, memberExpireCmd, memberRemoveCmd} {
+		cmd.MarkFlagRequired("username")
+	}
+	for _, cmd := range []*cobra.Command{memberUpdateCmd, memberExpireCmd} {
+		cmd.Flags().StringVar(&memberExpiresAt, "expires-at", "", "The membership expiration date (YYYY-MM-DD)")
+	}
+	memberListCmd.Flags().StringVarP(&memberFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+	addProfilesFlag(memberListCmd)
+	memberListCmd.Flags().BoolVar(&memberAll, "all", false, "List the inherited members too")
+	memberUpdateCmd.Flags().StringVarP(&memberAccess, "access", "L", "", "The access level, by name or number")
+	memberUpdateCmd.MarkFlagRequired("access")
+	memberExpireCmd.MarkFlagRequired("expires-at")
+	memberCmd.AddCommand(memberReplaceOwnersCmd)
+	memberSelector.addFlags(memberReplaceOwnersCmd)
+	memberReplaceOwnersCmd.Flags().StringSliceVar(&memberOwners, "owners", []string{}, "The usernames of the project owners")
+	memberReplaceOwnersCmd.MarkFlagRequired("owners")
+}
+
+// accessLevelName returns the name of the access level, or its number when unknown
+func accessLevelName(level gitlab.AccessLevelValue) string {
+	switch level {
+	case gitlab.NoPermissions:
+		return "no"
+	case gitlab.MinimalAccessPermissions:
+		return "minimal"
+	case gitlab.GuestPermissions:
+		return "guest"
+	case gitlab.ReporterPermissions:
+		return "reporter"
+	case gitlab.DeveloperPermissions:
+		return "developer"
+	case gitlab.MaintainerPermissions:
+		return "maintainer"
+	case gitlab.OwnerPermissions:
+		return "owner"
+	}
+	return strconv.Itoa(int(level))
+}
+
+type memberRecord struct {
+	Scope       string `json:"scope"`
+	Path        string `json:"path"`
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	AccessLevel string `json:"access_level"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+func newMemberRecord(o *owner, member *gitlab.ProjectMember) *memberRecord {
+	expiresAt := ""
+	if member.ExpiresAt != nil {
+		expiresAt = member.ExpiresAt.String()
+	}
+	return &memberRecord{
+		Scope:       o.scope(),
+		Path:        o.path(),
+		ID:          member.ID,
+		Username:    member.Username,
+		Name:        member.Name,
+		State:       member.State,
+		AccessLevel: accessLevelName(member.AccessLevel),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func (r *memberRecord) CSV() []string {
+	return []string{r.Scope, r.Path, strconv.Itoa(r.ID), r.Username, r.Name, r.State, r.AccessLevel, r.ExpiresAt}
+}
+
+func (r *memberRecord) Plain() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%s:%s:%s:%s", r.Scope, r.Path, r.ID, r.Username, r.Name, r.State, r.AccessLevel, r.ExpiresAt)
+}
+
+// listMembers returns the members of a project or a group, the group ones as project members
+func listMembers(helper *gitlabapi.GitlabApi, o *owner, all bool) ([]*gitlab.ProjectMember, error) {
+	if o.project != nil {
+		return helper.ListProjectMembers(o.project, all)
+	}
+	groupMembers, err := helper.ListGroupMembers(o.group, all)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*gitlab.ProjectMember, 0, len(groupMembers))
+	for _, member := range groupMembers {
+		members = append(members, &gitlab.ProjectMember{
+			ID:          member.ID,
+			Username:    member.Username,
+			Name:        member.Name,
+			State:       member.State,
+			ExpiresAt:   member.ExpiresAt,
+			AccessLevel: member.AccessLevel,
+			WebURL:      member.WebURL,
+			AvatarURL:   member.AvatarURL,
+		})
+	}
+	return members, nil
+}
+
+func findMember(members []*gitlab.ProjectMember, username string) *gitlab.ProjectMember {
+	for _, member := range members {
+		if strings.EqualFold(member.Username, username) {
+			return member
+		}
+	}
+	return nil
+}
+
+func doMemberList(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := memberSelector.selectOwners(gitlabAPI, memberScope)
+	if err != nil {
+		return errors.Wrap(err, "selecting owners")
+	}
+	for _, o := range owners {
+		members, err := listMembers(gitlabAPI, o, memberAll)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if memberUsername != "" && !strings.EqualFold(member.Username, memberUsername) {
+				continue
+			}
+			if err := printRecord(memberFormat, newMemberRecord(o, member)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eachMember applies the action to the --username direct member of every selected owner
+// and reports the result, missing when the user is not a direct member
+func eachMember(action func(*gitlabapi.GitlabApi, *owner, *gitlab.ProjectMember) error) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners, err := memberSelector.selectOwners(gitlabAPI, memberScope)
+	if err != nil {
+		return errors.Wrap(err, "selecting owners")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, o := range owners {
+		members, err := listMembers(gitlabAPI, o, false)
+		if err == nil {
+			member := findMember(members, memberUsername)
+			if member == nil {
+				utils.PrintCSV([]string{o.path(), "missing"})
+				countNotEdit++
+				continue
+			}
+			err = action(gitlabAPI, o, member)
+		}
+		if err != nil {
+			utils.PrintCSV([]string{o.path(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{o.path(), "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(owners), countEdit, countNotEdit)
+	return nil
+}
+
+// editMember sets the member access level and expiration date, the expiration is
+// always sent because Gitlab clears it when missing
+func editMember(helper *gitlabapi.GitlabApi, o *owner, member *gitlab.ProjectMember, level gitlab.AccessLevelValue, expiresAt *string) error {
+	if o.group != nil {
+		return helper.EditGroupMember(o.group, member.ID, &gitlab.EditGroupMemberOptions{AccessLevel: &level, ExpiresAt: expiresAt})
+	}
+	return helper.EditProjectMember(o.project, member.ID, &gitlab.EditProjectMemberOptions{AccessLevel: &level, ExpiresAt: expiresAt})
+}
+
+func parseMemberExpiresAt() (*string, error) {
+	if memberExpiresAt == "" {
+		return nil, nil
+	}
+	if _, err := time.Parse("2006-01-02", memberExpiresAt); err != nil {
+		return nil, errors.Wrapf(err, "parsing expiration date %q", memberExpiresAt)
+	}
+	return &memberExpiresAt, nil
+}
+
+func currentExpiresAt(member *gitlab.ProjectMember) *string {
+	if member.ExpiresAt == nil {
+		return nil
+	}
+	expiresAt := member.ExpiresAt.String()
+	return &expiresAt
+}
+
+func doMemberUpdate(cmd *cobra.Command, args []string) error {
+	level, err := parseAccessLevel(memberAccess)
+	if err != nil {
+		return err
+	}
+	expiresAt, err := parseMemberExpiresAt()
+	if err != nil {
+		return err
+	}
+	return eachMember(func(helper *gitlabapi.GitlabApi, o *owner, member *gitlab.ProjectMember) error {
+		if expiresAt == nil {
+			return editMember(helper, o, member, level, currentExpiresAt(member))
+		}
+		return editMember(helper, o, member, level, expiresAt)
+	})
+}
+
+func doMemberExpire(cmd *cobra.Command, args []string) error {
+	expiresAt, err := parseMemberExpiresAt()
+	if err != nil {
+		return err
+	}
+	return eachMember(func(helper *gitlabapi.GitlabApi, o *owner, member *gitlab.ProjectMember) error {
+		return editMember(helper, o, member, member.AccessLevel, expiresAt)
+	})
+}
+
+func removeMember(helper *gitlabapi.GitlabApi, o *owner, member *gitlab.ProjectMember) error {
+	if o.group != nil {
+		return helper.RemoveGroupMember(o.group, member.ID)
+	}
+	return helper.RemoveProjectMember(o.project, member.ID)
+}
+
+func doMemberRemove(cmd *cobra.Command, args []string) error {
+	return eachMember(removeMember)
+}
+
+func doMemberReplaceOwners(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners := make([]*gitlab.User, 0, len(memberOwners))
+	for _, username := range memberOwners {
+		user, err := gitlabAPI.GetUser(username)
+		if err != nil {
+			return err
+		}
+		owners = append(owners, user)
+	}
+	if len(owners) == 0 {
+		return errors.New("no owners specified: use --owners")
+	}
+	projects, err := memberSelector.selectProjects(gitlabAPI)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, project := range projects {
+		if err := gitlabAPI.ReplaceOwners(project, owners...); err != nil {
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{project.PathWithNamespace, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(projects), countEdit, countNotEdit)
+	return nil
+}
@@ -0,0 +1,336 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	offboardSelector    projectSelector
+	offboardDowngradeTo string
+	offboardTransferTo  string
+	offboardNoBlock     bool
+	offboardDryRun      bool
+	offboardYes         bool
+)
+
+var offboardCmd = &cobra.Command{
+	Use:   "offboard <username>",
+	Short: "Remove a leaving user from projects and groups",
+	Long: `Remove a leaving user from projects and groups
+
+  The plan of the offboarding is made of these actions:
+
+    remove      remove the user from the groups and projects where is a direct member
+    downgrade   or lower the user access to --downgrade-to, when given
+    transfer    move the user personal projects to the --transfer-to group
+    review      the user personal access tokens and ssh keys to revoke
+    block       block the user account, only when run as admin
+
+  As admin the memberships are the user memberships, in the groups and projects
+  matching --group and --project when given, otherwise they are looked for in
+  the selected groups and projects.
+
+  The plan is printed with --dry-run, otherwise it is applied and reported
+  with the status of every action.`,
+	Example: `  Print the plan to offboard user1
+
+  gitlab-api-client offboard user1 \
+    --transfer-to archive \
+    --dry-run \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Offboard user1 keeping guest access in projects
+
+  gitlab-api-client offboard user1 --downgrade-to guest --transfer-to archive --yes`,
+	Args: cobra.ExactArgs(1),
+	RunE: doOffboard,
+}
+
+func init() {
+	rootCmd.AddCommand(offboardCmd)
+	offboardSelector.addFlags(offboardCmd)
+	offboardCmd.Flags().StringVar(&offboardDowngradeTo, "downgrade-to", "", "The access level to downgrade the user to, by name or number, instead of removing")
+	offboardCmd.Flags().StringVar(&offboardTransferTo, "transfer-to", "", "The group (path or id) where the user personal projects are transferred")
+	offboardCmd.Flags().BoolVar(&offboardNoBlock, "no-block", false, "Do not block the user account")
+	offboardCmd.Flags().BoolVar(&offboardDryRun, "dry-run", false, "Print the plan without applying it")
+	offboardCmd.Flags().BoolVarP(&offboardYes, "yes", "y", false, "Apply the plan without asking for confirmation")
+}
+
+// offboardStep is an action of the offboarding plan, without run when it is only reported
+type offboardStep struct {
+	action string
+	target string
+	detail string
+	status string
+	run    func() error
+}
+
+func (s *offboardStep) print(status string) {
+	utils.PrintCSV([]string{s.action, s.target, s.detail, status})
+}
+
+// membershipOwners returns the groups and projects where the user is a direct member,
+// from the user memberships as admin, matching the selector patterns
+func membershipOwners(helper *gitlabapi.GitlabApi, user *gitlab.User) ([]*owner, error) {
+	groupRegexp, err := compilePattern(offboardSelector.group)
+	if err != nil {
+		return nil, err
+	}
+	projectRegexp, err := compilePattern(offboardSelector.project)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := helper.ListUserMemberships(user)
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]*owner, 0, len(memberships))
+	for _, membership := range memberships {
+		switch membership.SourceType {
+		case "Namespace":
+			group, err := helper.FindGroup(membership.SourceID)
+			if err != nil {
+				return nil, err
+			}
+			if group == nil {
+				return nil, errors.Errorf("group %d of the user memberships not found", membership.SourceID)
+			}
+			if groupRegexp != nil && !groupRegexp.MatchString(group.Name) {
+				log.Debugf("skipped gitlab group '%s' not matching '%s'", group.Name, offboardSelector.group)
+				continue
+			}
+			owners = append(owners, &owner{group: group})
+		case "Project":
+			project, err := helper.GetProject(membership.SourceID)
+			if err != nil {
+				return nil, err
+			}
+			if groupRegexp != nil && (project.Namespace == nil || !groupRegexp.MatchString(project.Namespace.Name)) {
+				log.Debugf("skipped gitlab project '%s' not in a group matching '%s'", project.PathWithNamespace, offboardSelector.group)
+				continue
+			}
+			if projectRegexp != nil && !projectRegexp.MatchString(project.Name) {
+				log.Debugf("skipped gitlab project '%s' not matching '%s'", project.Name, offboardSelector.project)
+				continue
+			}
+			owners = append(owners, &owner{project: project})
+		}
+	}
+	return owners, nil
+}
+
+// selectedOwners returns the groups and the projects of the selector, to look for
+// the user memberships without admin access
+func selectedOwners(helper *gitlabapi.GitlabApi) ([]*owner, error) {
+	groups, err := offboardSelector.selectOwners(helper, "group")
+	if err != nil {
+		return nil, err
+	}
+	projects, err := offboardSelector.selectOwners(helper, "project")
+	if err != nil {
+		return nil, err
+	}
+	return append(groups, projects...), nil
+}
+
+// planMemberships returns the steps to remove or downgrade the user memberships
+func planMemberships(helper *gitlabapi.GitlabApi, user *gitlab.User, admin bool, downgrade *gitlab.AccessLevelValue) ([]*offboardStep, error) {
+	var owners []*owner
+	var err error
+	if admin {
+		owners, err = membershipOwners(helper, user)
+	} else {
+		log.Warn("user memberships are only listed when run as admin, looking for them in the selected groups and projects")
+		owners, err = selectedOwners(helper)
+	}
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]*offboardStep, 0)
+	for _, o := range owners {
+		members, err := listMembers(helper, o, false)
+		if err != nil {
+			return nil, err
+		}
+		member := findMember(members, user.Username)
+		if member == nil {
+			continue
+		}
+		o, member := o, member
+		target := fmt.Sprintf("%s:%s", o.scope(), o.path())
+		if downgrade == nil {
+			steps = append(steps, &offboardStep{
+				action: "remove",
+				target: target,
+				detail: accessLevelName(member.AccessLevel),
+				run: func() error {
+					return removeMember(helper, o, member)
+				},
+			})
+			continue
+		}
+		if member.AccessLevel <= *downgrade {
+			log.Debugf("kept user '%s' %s access in %s", user.Username, accessLevelName(member.AccessLevel), target)
+			continue
+		}
+		steps = append(steps, &offboardStep{
+			action: "downgrade",
+			target: target,
+			detail: fmt.Sprintf("%s to %s", accessLevelName(member.AccessLevel), accessLevelName(*downgrade)),
+			run: func() error {
+				return editMember(helper, o, member, *downgrade, currentExpiresAt(member))
+			},
+		})
+	}
+	return steps, nil
+}
+
+func planOffboard(helper *gitlabapi.GitlabApi, user *gitlab.User) ([]*offboardStep, error) {
+	var downgrade *gitlab.AccessLevelValue
+	if offboardDowngradeTo != "" {
+		level, err := parseAccessLevel(offboardDowngradeTo)
+		if err != nil {
+			return nil, err
+		}
+		downgrade = &level
+	}
+	current, err := helper.CurrentUser()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := planMemberships(helper, user, current.IsAdmin, downgrade)
+	if err != nil {
+		return nil, errors.Wrap(err, "planning memberships")
+	}
+	projects, err := helper.ListUserProjects(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		project := project
+		step := &offboardStep{action: "transfer", target: "project:" + project.PathWithNamespace, detail: offboardTransferTo}
+		if offboardTransferTo == "" {
+			step.status = "skipped no --transfer-to"
+		} else {
+			step.run = func() error {
+				_, err := helper.TransferProject(project, offboardTransferTo)
+				return err
+			}
+		}
+		steps = append(steps, step)
+	}
+	if current.IsAdmin {
+		tokens, err := helper.ListUserPersonalAccessTokens(user)
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range tokens {
+			if !token.Active {
+				continue
+			}
+			steps = append(steps, &offboardStep{
+				action: "review",
+				target: "token:" + strconv.Itoa(token.ID),
+				detail: token.Name,
+				status: "revoke",
+			})
+		}
+	} else {
+		log.Warn("personal access tokens are only listed when run as admin")
+	}
+	keys, err := helper.ListUserSSHKeys(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		steps = append(steps, &offboardStep{
+			action: "review",
+			target: "ssh-key:" + strconv.Itoa(key.ID),
+			detail: key.Title,
+			status: "revoke",
+		})
+	}
+	block := &offboardStep{action: "block", target: "user:" + user.Username}
+	switch {
+	case offboardNoBlock:
+		block.status = "skipped --no-block"
+	case !current.IsAdmin:
+		block.status = "skipped not admin"
+	case user.State == "blocked":
+		block.status = "skipped already blocked"
+	default:
+		block.run = func() error {
+			return helper.BlockUser(user)
+		}
+	}
+	return append(steps, block), nil
+}
+
+func doOffboard(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	user, err := gitlabAPI.GetUser(args[0])
+	if err != nil {
+		return errors.Wrap(err, "getting user")
+	}
+	steps, err := planOffboard(gitlabAPI, user)
+	if err != nil {
+		return err
+	}
+	actions := 0
+	for _, step := range steps {
+		if step.run != nil {
+			actions++
+		}
+	}
+	if offboardDryRun {
+		for _, step := range steps {
+			if step.run != nil {
+				step.print("planned")
+			} else {
+				step.print(step.status)
+			}
+		}
+		return nil
+	}
+	if actions > 0 && !offboardYes {
+		ok, err := utils.Confirm(fmt.Sprintf("Apply %d actions to offboard user %s?", actions, user.Username))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Info("offboard cancelled")
+			return nil
+		}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, step := range steps {
+		if step.run == nil {
+			step.print(step.status)
+			countNotEdit++
+			continue
+		}
+		if err := step.run(); err != nil {
+			step.print(fmt.Sprintf("Fail %v", err))
+			countNotEdit++
+		} else {
+			step.print("ok")
+			countEdit++
+		}
+	}
+	printTotals(len(steps), countEdit, countNotEdit)
+	return nil
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var onboardFrom string
+
+var onboardCmd = &cobra.Command{
+	Use:   "onboard",
+	Short: "Add users to groups and projects from a CSV file",
+	Long: `Add users to groups and projects from a CSV file
+
+  The --from file has CSV rows (username,path,access,expires_at) where path is
+  the group or project full path, access is the access level by name or number
+  (reporter when empty) and expires_at is an optional YYYY-MM-DD date. A first
+  row starting with username is skipped as header.
+
+  The memberships are added when missing and updated when the access or the
+  expiration differ, so the same file can be applied many times.`,
+	Example: `  Onboard the users of users.csv
+
+  gitlab-api-client onboard \
+    --from users.csv \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+	RunE: doOnboard,
+}
+
+func init() {
+	rootCmd.AddCommand(onboardCmd)
+	onboardCmd.Flags().StringVarP(&onboardFrom, "from", "f", "", "CSV input file (username,path,access,expires_at) to read memberships from")
+	onboardCmd.MarkFlagRequired("from")
+}
+
+// onboardRow is a membership read from the --from file
+type onboardRow struct {
+	line      int
+	username  string
+	path      string
+	level     gitlab.AccessLevelValue
+	expiresAt *string
+	err       error
+}
+
+func (r *onboardRow) print(status string) {
+	utils.PrintCSV([]string{r.username, r.path, status})
+}
+
+func parseOnboardRow(line int, rec []string) *onboardRow {
+	for len(rec) < 4 {
+		rec = append(rec, "")
+	}
+	row := &onboardRow{
+		line:     line,
+		username: strings.TrimSpace(rec[0]),
+		path:     strings.Trim(strings.TrimSpace(rec[1]), "/"),
+	}
+	switch {
+	case row.username == "":
+		row.err = errors.Errorf("line %d: missing username", line)
+		return row
+	case row.path == "":
+		row.err = errors.Errorf("line %d: missing group or project path", line)
+		return row
+	}
+	access := strings.TrimSpace(rec[2])
+	if access == "" {
+		access = "reporter"
+	}
+	level, err := parseAccessLevel(access)
+	if err != nil {
+		row.err = errors.Wrapf(err, "line %d", line)
+		return row
+	}
+	row.level = level
+	if expiresAt := strings.TrimSpace(rec[3]); expiresAt != "" {
+		if _, err := time.Parse("2006-01-02", expiresAt); err != nil {
+			row.err = errors.Wrapf(err, "line %d: parsing expiration date %q", line, expiresAt)
+			return row
+		}
+		row.expiresAt = &expiresAt
+	}
+	return row
+}
+
+func readOnboardRows() ([]*onboardRow, error) {
+	f, err := os.Open(onboardFrom)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening input file %q", onboardFrom)
+	}
+	defer f.Close()
+	rows := make([]*onboardRow, 0)
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing csv data from file %q", onboardFrom)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "username") {
+			continue
+		}
+		rows = append(rows, parseOnboardRow(line, rec))
+	}
+	return rows, nil
+}
+
+// findOwner returns the project or else the group with the full path
+func findOwner(helper *gitlabapi.GitlabApi, path string) (*owner, error) {
+	project, err := helper.FindProject(path)
+	if err != nil {
+		return nil, err
+	}
+	if project != nil {
+		return &owner{project: project}, nil
+	}
+	group, err := helper.FindGroup(path)
+	if err != nil {
+		return nil, err
+	}
+	if group != nil {
+		return &owner{group: group}, nil
+	}
+	return nil, errors.Errorf("no group or project found for path %q", path)
+}
+
+func sameDate(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// onboard adds or updates the membership and returns the status: added, updated or exists
+func onboard(helper *gitlabapi.GitlabApi, o *owner, user *gitlab.User, row *onboardRow) (string, error) {
+	members, err := listMembers(helper, o, false)
+	if err != nil {
+		return "", err
+	}
+	member := findMember(members, user.Username)
+	if member == nil {
+		if o.group != nil {
+			err = helper.AddGroupMember(o.group, user, row.level, row.expiresAt)
+		} else {
+			err = helper.AddProjectMember(o.project, user, row.level, row.expiresAt)
+		}
+		return "added", err
+	}
+	if member.AccessLevel == row.level && sameDate(currentExpiresAt(member), row.expiresAt) {
+		return "exists", nil
+	}
+	return "updated", editMember(helper, o, member, row.level, row.expiresAt)
+}
+
+func doOnboard(cmd *cobra.Command, args []string) error {
+	rows, err := readOnboardRows()
+	if err != nil {
+		return err
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	owners := make(map[string]*owner)
+	countEdit := 0
+	countNotEdit := 0
+	for _, row := range rows {
+		if row.err != nil {
+			row.print(fmt.Sprintf("Fail %v", row.err))
+			countNotEdit++
+			continue
+		}
+		user, err := gitlabAPI.GetUser(row.username)
+		if err != nil {
+			row.print(fmt.Sprintf("Fail line %d: %v", row.line, err))
+			countNotEdit++
+			continue
+		}
+		o, ok := owners[row.path]
+		if !ok {
+			o, err = findOwner(gitlabAPI, row.path)
+			if err != nil {
+				row.print(fmt.Sprintf("Fail line %d: %v", row.line, err))
+				countNotEdit++
+				continue
+			}
+			owners[row.path] = o
+		}
+		status, err := onboard(gitlabAPI, o, user, row)
+		switch {
+		case err != nil:
+			row.print(fmt.Sprintf("Fail line %d: %v", row.line, err))
+			countNotEdit++
+		case status == "exists":
+			row.print(status)
+			countNotEdit++
+		default:
+			row.print(status)
+			countEdit++
+		}
+	}
+	printTotals(len(rows), countEdit, countNotEdit)
+	return nil
+}
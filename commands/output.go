@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"strconv"
+
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+)
+
+// record is a listing row printable in any of the listing formats
+type record interface {
+	CSV() []string
+	Plain() string
+}
+
+func printRecord(format string, r record) error {
+	if profileColumn {
+		r = &profileRecord{profile: activeProfile, record: r}
+	}
+	switch format {
+	case "csv":
+		return utils.PrintCSV(r.CSV())
+	case "plain":
+		utils.PrintPlain(r.Plain())
+		return nil
+	case "json":
+		return utils.PrintJSON(r)
+	default:
+		return errors.Errorf("unknown list format: %s", format)
+	}
+}
+
+func printTotals(total, edit, notEdit int) {
+	utils.PrintCSV([]string{"total", strconv.Itoa(total)})
+	utils.PrintCSV([]string{"edit", strconv.Itoa(edit)})
+	utils.PrintCSV([]string{"notEdit", strconv.Itoa(notEdit)})
+}
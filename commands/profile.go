@@ -0,0 +1,343 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	profileKey  = "profile"
+	profilesKey = "profiles"
+)
+
+var (
+	// activeProfile is the profile of the settings used by gitlabAPI, none when empty
+	activeProfile string
+	// profileColumn prints the active profile in the records of listings run in several profiles
+	profileColumn bool
+)
+
+var (
+	profileFormat string
+	profilesVal   []string
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "List, show and select the instance profiles of the configuration",
+	Long: `List, show and select the instance profiles of the configuration
+
+  The profiles section of the configuration has named instance profiles, each
+  with its own api-url, private-token, trusted-certificates and the defaults of
+  the command flags. The profile in use is given by --profile or by the
+  profile setting, which is saved by 'profile use', and the settings missing
+  in the profile are taken from the gitlab section, except the tokens of a
+  profile with its own api-url.
+
+  profile: production
+  profiles:
+    production:
+      api-url: https://gitlab.example.com/api/v4/
+      private-token: token
+    staging:
+      api-url: https://gitlab.staging.example.com/api/v4/
+      private-token: token
+      trusted-certificates:
+        - "@staging.pem"
+      defaults:
+        group: test1
+
+  The listing commands with --profiles run the same query in every profile,
+  or in all of them, and print the results with a profile column.`,
+	Example: `  List the projects of the test1 group in the staging and production profiles
+
+  gitlab-api-client list-projects --group test1 --profiles staging,production`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles of the configuration",
+	RunE:  doProfileList,
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Save the profile used by default in the configuration file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  doProfileUse,
+}
+
+var profileShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "Show the settings of the profile (default the profile in use)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  doProfileShow,
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	// the profile commands work with an unknown profile in use, to fix it
+	profileCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error { return nil }
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileShowCmd)
+	profileListCmd.Flags().StringVarP(&profileFormat, "format", "F", "csv", "The listing format (json, csv or plain) - if is Debug does not print")
+}
+
+// profileNames returns the sorted names of the configured profiles
+func profileNames() []string {
+	names := make([]string, 0)
+	for name := range viper.GetStringMap(profilesKey) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkProfile(name string) error {
+	if !viper.IsSet(profilesKey + "." + name) {
+		return errors.Errorf("unknown profile %q (%s)", name, strings.Join(profileNames(), ", "))
+	}
+	return nil
+}
+
+// settingKey returns the viper key of the setting, the one of the active profile
+// unless the setting flag is given or the profile does not have it
+func settingKey(setting, global string) string {
+	if flag := rootCmd.PersistentFlags().Lookup(setting); flag != nil && flag.Changed {
+		return global
+	}
+	if activeProfile != "" {
+		key := profilesKey + "." + activeProfile + "." + setting
+		if viper.IsSet(key) {
+			return key
+		}
+	}
+	return global
+}
+
+// sliceValue is the value of the slice flags, which Set appends to once set
+type sliceValue interface {
+	Replace([]string) error
+	GetSlice() []string
+}
+
+// profileDefaults restores the flags set by the defaults of the active profile
+var profileDefaults = make(map[string]func() error)
+
+// applyProfileDefaults sets the flags not given to the defaults of the active profile
+func applyProfileDefaults(cmd *cobra.Command, args []string) error {
+	if activeProfile == "" {
+		return nil
+	}
+	if err := checkProfile(activeProfile); err != nil {
+		return err
+	}
+	defaults := profilesKey + "." + activeProfile + ".defaults"
+	for name := range viper.GetStringMap(defaults) {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		var restore func() error
+		var err error
+		if slice, ok := flag.Value.(sliceValue); ok {
+			previous := slice.GetSlice()
+			restore = func() error { return slice.Replace(previous) }
+			err = slice.Replace(viper.GetStringSlice(defaults + "." + name))
+		} else {
+			value, previous := flag.Value, flag.Value.String()
+			restore = func() error { return value.Set(previous) }
+			err = value.Set(viper.GetString(defaults + "." + name))
+		}
+		if _, ok := profileDefaults[name]; !ok {
+			profileDefaults[name] = restore
+		}
+		if err != nil {
+			return errors.Wrapf(err, "setting profile %q default of --%s", activeProfile, name)
+		}
+	}
+	return nil
+}
+
+// resetProfileDefaults restores the flags set by the defaults of the active profile
+func resetProfileDefaults() error {
+	for name, restore := range profileDefaults {
+		if err := restore(); err != nil {
+			return errors.Wrapf(err, "resetting profile %q default of --%s", activeProfile, name)
+		}
+		delete(profileDefaults, name)
+	}
+	return nil
+}
+
+// addProfilesFlag adds --profiles to the listing command, which runs it in every
+// profile printing the records with a profile column
+func addProfilesFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&profilesVal, "profiles", []string{}, "The profiles to run the listing in, or all")
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(profilesVal) == 0 {
+			return run(cmd, args)
+		}
+		profiles := profilesVal
+		if len(profiles) == 1 && profiles[0] == "all" {
+			profiles = profileNames()
+		}
+		for _, name := range profiles {
+			if err := checkProfile(strings.ToLower(name)); err != nil {
+				return err
+			}
+		}
+		defer func(profile string) {
+			activeProfile = profile
+			profileColumn = false
+		}(activeProfile)
+		profileColumn = true
+		for _, name := range profiles {
+			// every profile runs with its own defaults, not the ones of the previous profile
+			if err := resetProfileDefaults(); err != nil {
+				return err
+			}
+			activeProfile = strings.ToLower(name)
+			if err := applyProfileDefaults(cmd, args); err != nil {
+				return err
+			}
+			log.Debugf("running in profile '%s'", activeProfile)
+			if err := run(cmd, args); err != nil {
+				return errors.Wrapf(err, "profile %s", activeProfile)
+			}
+		}
+		return nil
+	}
+}
+
+// profileRecord is a record of a listing run in several profiles
+type profileRecord struct {
+	profile string
+	record
+}
+
+func (r *profileRecord) CSV() []string {
+	return append([]string{r.profile}, r.record.CSV()...)
+}
+
+func (r *profileRecord) Plain() string {
+	return r.profile + ":" + r.record.Plain()
+}
+
+func (r *profileRecord) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(r.record)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["profile"] = r.profile
+	return json.Marshal(fields)
+}
+
+// profileSummary is a profile of the profile list
+type profileSummary struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	APIURL  string `json:"api_url"`
+}
+
+func (p *profileSummary) CSV() []string {
+	current := ""
+	if p.Current {
+		current = "*"
+	}
+	return []string{p.Name, current, p.APIURL}
+}
+
+func (p *profileSummary) Plain() string {
+	return strings.Join(p.CSV(), ":")
+}
+
+func doProfileList(cmd *cobra.Command, args []string) error {
+	for _, name := range profileNames() {
+		summary := &profileSummary{
+			Name:    name,
+			Current: name == activeProfile,
+			APIURL:  viper.GetString(profilesKey + "." + name + ".api-url"),
+		}
+		if err := printRecord(profileFormat, summary); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func doProfileUse(cmd *cobra.Command, args []string) error {
+	name := strings.ToLower(args[0])
+	if err := checkProfile(name); err != nil {
+		return err
+	}
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return errors.New("no configuration file to save the profile in")
+	}
+	// a new viper has only the file settings, not the flags and environment ones
+	config := viper.New()
+	config.SetConfigFile(file)
+	if err := config.ReadInConfig(); err != nil {
+		return errors.Wrapf(err, "reading configuration file %q", file)
+	}
+	config.Set(profileKey, name)
+	if err := config.WriteConfig(); err != nil {
+		return errors.Wrapf(err, "writing configuration file %q", file)
+	}
+	log.Infof("using profile '%s' in %s", name, file)
+	return nil
+}
+
+func doProfileShow(cmd *cobra.Command, args []string) error {
+	name := activeProfile
+	if len(args) > 0 {
+		name = strings.ToLower(args[0])
+	}
+	if name == "" {
+		return errors.New("no profile in use")
+	}
+	if err := checkProfile(name); err != nil {
+		return err
+	}
+	settings := viper.GetStringMap(profilesKey + "." + name)
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Printf("profile: %s\n", name)
+	for _, key := range keys {
+		fmt.Printf("%s: %v\n", key, maskSecrets(key, settings[key]))
+	}
+	return nil
+}
+
+// maskSecrets returns the setting value with the secrets masked, in the nested settings too
+func maskSecrets(key string, value interface{}) interface{} {
+	if nested, ok := value.(map[string]interface{}); ok {
+		masked := make(map[string]interface{}, len(nested))
+		for k, v := range nested {
+			masked[k] = maskSecrets(k, v)
+		}
+		return masked
+	}
+	if (key == clientKey || utils.IsSecretName(key)) && value != "" && value != nil {
+		return "********"
+	}
+	return value
+}
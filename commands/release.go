@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	releaseCreateSelector   projectSelector
+	releaseCreateTag        string
+	releaseCreateRef        string
+	releaseCreateName       string
+	releaseCreateMessage    string
+	releaseCreateNotes      string
+	releaseCreateNotesFile  string
+	releaseCreateAssets     []string
+	releaseCreateMilestones []string
+	releaseCreateFrom       string
+)
+
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Gitlab projects releases",
+}
+
+var releaseCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a tag and a release in one or more gitlab projects",
+	Long: `Create a tag and a release in one or more gitlab projects
+
+  An annotated tag is created on the ref (default branch head when empty) and
+  then a release with the notes, asset links and milestones. Existing tags and
+  releases are kept, so the command can be run again safely.
+
+  The --from file has CSV rows (project,ref,version) where project is the
+  path with namespace. Empty ref or version take the --ref and --tag values.`,
+	RunE: doReleaseCreate,
+	Example: `  Release 1.2.0 on every project in group platform
+
+  gitlab-api-client release create \
+    --group platform \
+    --tag v1.2.0 \
+    --notes-file NOTES.md \
+    --asset 'Docs=https://docs.localhost/1.2.0' \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Release with per project refs and versions
+
+  gitlab-api-client release create --from releases.csv`,
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseCreateCmd)
+	releaseCreateSelector.addFlags(releaseCreateCmd)
+	releaseCreateCmd.Flags().StringVarP(&releaseCreateTag, "tag", "T", "", "The tag (version) to create")
+	releaseCreateCmd.Flags().StringVarP(&releaseCreateRef, "ref", "r", "", "The branch or commit to tag (default branch when empty)")
+	releaseCreateCmd.Flags().StringVar(&releaseCreateName, "name", "", "The release name (tag when empty)")
+	releaseCreateCmd.Flags().StringVarP(&releaseCreateMessage, "message", "m", "", "The annotated tag message (default 'Release <tag>')")
+	releaseCreateCmd.Flags().StringVarP(&releaseCreateNotes, "notes", "n", "", "The release notes")
+	releaseCreateCmd.Flags().StringVar(&releaseCreateNotesFile, "notes-file", "", "The file to read the release notes from")
+	releaseCreateCmd.Flags().StringArrayVar(&releaseCreateAssets, "asset", []string{}, "The release asset link (name=url)")
+	releaseCreateCmd.Flags().StringSliceVar(&releaseCreateMilestones, "milestone", []string{}, "The milestones associated to the release")
+	releaseCreateCmd.Flags().StringVarP(&releaseCreateFrom, "from", "f", "", "CSV input file (project,ref,version) to read projects from")
+}
+
+func releaseAssetLinks(assets []string) ([]*gitlab.ReleaseAssetLink, error) {
+	links := make([]*gitlab.ReleaseAssetLink, 0)
+	for _, asset := range assets {
+		parts := strings.SplitN(asset, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid asset link %q (must be name=url)", asset)
+		}
+		links = append(links, &gitlab.ReleaseAssetLink{Name: parts[0], URL: parts[1]})
+	}
+	return links, nil
+}
+
+// releaseSpec returns the release to create, using the flags for the empty values
+func releaseSpec(ref, version, notes string, links []*gitlab.ReleaseAssetLink) *gitlabapi.ReleaseSpec {
+	if ref == "" {
+		ref = releaseCreateRef
+	}
+	if version == "" {
+		version = releaseCreateTag
+	}
+	message := releaseCreateMessage
+	if message == "" {
+		message = fmt.Sprintf("Release %s", version)
+	}
+	return &gitlabapi.ReleaseSpec{
+		Tag:        version,
+		Ref:        ref,
+		Name:       releaseCreateName,
+		Message:    message,
+		Notes:      notes,
+		Milestones: releaseCreateMilestones,
+		Links:      links,
+	}
+}
+
+type releaseTarget struct {
+	path    string
+	project *gitlab.Project
+	spec    *gitlabapi.ReleaseSpec
+	err     error
+}
+
+func readReleaseTargets(helper *gitlabapi.GitlabApi, notes string, links []*gitlab.ReleaseAssetLink) ([]*releaseTarget, error) {
+	f, err := os.Open(releaseCreateFrom)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening input file %q", releaseCreateFrom)
+	}
+	defer f.Close()
+	targets := make([]*releaseTarget, 0)
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing csv data from file %q", releaseCreateFrom)
+		}
+		for len(rec) < 3 {
+			rec = append(rec, "")
+		}
+		target := &releaseTarget{path: rec[0], spec: releaseSpec(rec[1], rec[2], notes, links)}
+		target.project, target.err = helper.FindProject(rec[0])
+		if target.err == nil && target.project == nil {
+			target.err = errors.Errorf("project %q not found", rec[0])
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func doReleaseCreate(cmd *cobra.Command, args []string) error {
+	if releaseCreateFrom == "" && releaseCreateTag == "" {
+		return errors.New("no tag specified: use --tag or --from")
+	}
+	links, err := releaseAssetLinks(releaseCreateAssets)
+	if err != nil {
+		return err
+	}
+	notes := releaseCreateNotes
+	if releaseCreateNotesFile != "" {
+		bs, err := ioutil.ReadFile(releaseCreateNotesFile)
+		if err != nil {
+			return errors.Wrapf(err, "reading notes file %q", releaseCreateNotesFile)
+		}
+		notes = string(bs)
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	var targets []*releaseTarget
+	if releaseCreateFrom != "" {
+		targets, err = readReleaseTargets(gitlabAPI, notes, links)
+		if err != nil {
+			return err
+		}
+	} else {
+		projects, err := releaseCreateSelector.selectProjects(gitlabAPI)
+		if err != nil {
+			return errors.Wrap(err, "selecting projects")
+		}
+		for _, project := range projects {
+			targets = append(targets, &releaseTarget{path: project.PathWithNamespace, project: project, spec: releaseSpec("", "", notes, links)})
+		}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, target := range targets {
+		if target.err != nil {
+			utils.PrintCSV([]string{target.path, target.spec.Tag, fmt.Sprintf("Fail %v", target.err)})
+			countNotEdit++
+			continue
+		}
+		if target.spec.Tag == "" {
+			log.Warnf("skipped gitlab project '%s' without version", target.project.PathWithNamespace)
+			utils.PrintCSV([]string{target.project.PathWithNamespace, "", "Fail missing version"})
+			countNotEdit++
+			continue
+		}
+		status, err := gitlabAPI.CreateRelease(target.project, target.spec)
+		if err != nil {
+			utils.PrintCSV([]string{target.project.PathWithNamespace, target.spec.Tag, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{target.project.PathWithNamespace, target.spec.Tag, status})
+			countEdit++
+		}
+	}
+	printTotals(len(targets), countEdit, countNotEdit)
+	return nil
+}
@@ -1,70 +1,229 @@
 package commands
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
 	"github.com/janusky/gitlab-api-client/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
 )
 
 var removeProjectsCmd = &cobra.Command{
 	Use:     "remove-projects",
 	Aliases: []string{"remove-project"},
 	Short:   "Remove one or more gitlab projects",
-	RunE:    doRemoveProjects,
+	Long: `Remove one or more gitlab projects
+
+  The projects are given by name or matching --project in the group with the
+  full path --group, or in the user namespace without --group. The path, size
+  and last activity of the projects are shown to confirm the removal, unless
+  --yes.
+
+  With --backup export the project export, or with --backup archive the
+  repository archive, is saved in --backup-dir before the removal, which is
+  skipped if the backup fails.
+
+  When Gitlab delays the deletion of projects, the projects are reported as
+  scheduled for deletion and the restore-projects command undoes it.`,
+	RunE: doRemoveProjects,
 	Example: `  Remove one or more gitlab projects
 
   gitlab-api-client remove-project test1-db \
-	--group test1 \
-	--api-url https://gitlab.localhost/api/v4/ \
+    --group test1 \
+    --api-url https://gitlab.localhost/api/v4/ \
     --private-token token \
-    --trusted-certificates @certificates.pem`,
+    --trusted-certificates @certificates.pem
+
+  Remove the tmp- projects of test1 group saving their exports
+
+  gitlab-api-client remove-projects --group test1 --project '^tmp-' --backup export --backup-dir backups`,
 }
 
 var (
-	removeProjectsGroup string
+	removeProjectsGroup         string
+	removeProjectsProject       string
+	removeProjectsUser          string
+	removeProjectsYes           bool
+	removeProjectsBackup        string
+	removeProjectsBackupDir     string
+	removeProjectsExportTimeout time.Duration
 )
 
 func init() {
 	rootCmd.AddCommand(removeProjectsCmd)
-	removeProjectsCmd.Flags().StringVarP(&removeProjectsGroup, "group", "g", "", "The group where projects will be removed")
+	removeProjectsCmd.Flags().StringVarP(&removeProjectsGroup, "group", "g", "", "The full path of the group where projects will be removed")
+	removeProjectsCmd.Flags().StringVarP(&removeProjectsProject, "project", "p", "", "The pattern to match projects")
+	removeProjectsCmd.Flags().StringVar(&removeProjectsUser, "user", "", "The user whose namespace projects will be removed from without --group, as admin (default the current user)")
+	removeProjectsCmd.Flags().BoolVarP(&removeProjectsYes, "yes", "y", false, "Remove projects without asking for confirmation")
+	removeProjectsCmd.Flags().StringVar(&removeProjectsBackup, "backup", "none", "The backup before removing (none, export or archive)")
+	removeProjectsCmd.Flags().StringVar(&removeProjectsBackupDir, "backup-dir", ".", "The directory where backups are saved")
+	removeProjectsCmd.Flags().DurationVar(&removeProjectsExportTimeout, "export-timeout", 10*time.Minute, "The time to wait for each project export")
+}
+
+// selectNamespaceProjects returns the projects of the group with the full path, or of
+// the user namespace without group, with the names or matching the project pattern,
+// and the names not found
+func selectNamespaceProjects(helper *gitlabapi.GitlabApi, group, pattern, user string, names []string) ([]*gitlab.Project, []string, error) {
+	if group != "" && user != "" {
+		return nil, nil, errors.New("--user is the namespace without group: do not use it with --group")
+	}
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	var projects []*gitlab.Project
+	if group != "" {
+		g, err := helper.FindGroup(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		if g == nil {
+			return nil, nil, errors.Errorf("group %q not found", group)
+		}
+		projects, err = helper.ListGroupProjects(g)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "listing group %q projects", group)
+		}
+	} else {
+		projects, err = helper.ListUserNamespaceProjects(user)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	found := make(map[string]bool)
+	selected := make([]*gitlab.Project, 0)
+	for _, project := range projects {
+		named := false
+		for _, name := range names {
+			if project.Name == name {
+				named = true
+				found[name] = true
+			}
+		}
+		if named || (re != nil && re.MatchString(project.Name)) {
+			selected = append(selected, project)
+		}
+	}
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return selected, missing, nil
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// confirmRemoval shows the projects path, size and last activity and asks to remove them
+func confirmRemoval(helper *gitlabapi.GitlabApi, projects []*gitlab.Project) (bool, error) {
+	for _, project := range projects {
+		size := "?"
+		if p, err := helper.GetProjectStatistics(project); err != nil {
+			log.Warnf("%v", err)
+		} else if p.Statistics != nil {
+			size = formatBytes(p.Statistics.StorageSize)
+		}
+		lastActivity := "never"
+		if project.LastActivityAt != nil {
+			lastActivity = project.LastActivityAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(os.Stderr, "  %s\t%s\tlast activity %s\n", project.PathWithNamespace, size, lastActivity)
+	}
+	return utils.Confirm(fmt.Sprintf("Remove %d projects?", len(projects)))
+}
+
+// backupProject saves the project export or repository archive in the backup directory
+func backupProject(helper *gitlabapi.GitlabApi, project *gitlab.Project) error {
+	var data []byte
+	var err error
+	switch removeProjectsBackup {
+	case "none":
+		return nil
+	case "export":
+		data, err = helper.ExportProject(project, removeProjectsExportTimeout)
+	case "archive":
+		data, err = helper.RepositoryArchive(project)
+	}
+	if err != nil {
+		return err
+	}
+	name := strings.Replace(project.PathWithNamespace, "/", "_", -1) + "-" + removeProjectsBackup + ".tar.gz"
+	file := filepath.Join(removeProjectsBackupDir, name)
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		return errors.Wrapf(err, "writing backup file %q", file)
+	}
+	log.Infof("saved project '%s' backup in %s", project.PathWithNamespace, file)
+	return nil
 }
 
 func doRemoveProjects(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
+	if len(args) < 1 && removeProjectsProject == "" {
 		return errors.Errorf("no project names specified")
 	}
+	switch removeProjectsBackup {
+	case "none", "export", "archive":
+	default:
+		return errors.Errorf("unknown backup: %s (none, export or archive)", removeProjectsBackup)
+	}
 	gitlabAPI, err := gitlabAPI()
 	if err != nil {
 		return errors.Wrap(err, "creating gitlab api")
 	}
-	if removeProjectsGroup != "" {
-		g, _, err := gitlabAPI.Client.Groups.GetGroup(removeProjectsGroup)
+	projects, missing, err := selectNamespaceProjects(gitlabAPI, removeProjectsGroup, removeProjectsProject, removeProjectsUser, args)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	if len(projects) > 0 && !removeProjectsYes {
+		ok, err := confirmRemoval(gitlabAPI, projects)
 		if err != nil {
-			return errors.Wrapf(err, "getting group %q", removeProjectsGroup)
+			return err
 		}
-		groupProjects, err := gitlabAPI.ListGroupProjects(g)
-		if err != nil {
-			return errors.Wrapf(err, "listing group %q projects", removeProjectsGroup)
+		if !ok {
+			log.Info("removal cancelled")
+			return nil
 		}
-		for _, projectName := range args {
-			found := false
-			for _, project := range groupProjects {
-				if project.Name == projectName {
-					_, err := gitlabAPI.Client.Projects.DeleteProject(project.ID)
-					if err != nil {
-						return errors.Wrapf(err, "removing project %q (%d) from group %q", projectName, project.ID, removeProjectsGroup)
-					}
-					log.Infof("removed project %q (%d) from group %q", projectName, project.ID, removeProjectsGroup)
-					found = true
-					break
-				}
-			}
-			if !found {
-				return errors.Errorf("project %q not found in group %q", projectName, removeProjectsGroup)
-			}
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, name := range missing {
+		utils.PrintCSV([]string{name, "missing"})
+		countNotEdit++
+	}
+	adjournedPeriod := gitlabAPI.DeletionAdjournedPeriod()
+	for _, project := range projects {
+		status := ""
+		err := backupProject(gitlabAPI, project)
+		if err == nil {
+			status, err = gitlabAPI.DeleteProject(project, adjournedPeriod)
+		}
+		if err != nil {
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{project.PathWithNamespace, status})
+			countEdit++
 		}
-		return nil
 	}
-	return utils.NotImplementedError("user projects removal")
+	printTotals(len(projects)+len(missing), countEdit, countNotEdit)
+	return nil
 }
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var restoreProjectsCmd = &cobra.Command{
+	Use:     "restore-projects",
+	Aliases: []string{"restore-project", "restore"},
+	Short:   "Restore gitlab projects scheduled for deletion",
+	Long: `Restore gitlab projects scheduled for deletion
+
+  The projects are given by name or matching --project in the group with the
+  full path --group, or in the user namespace without --group, and only the
+  ones marked for deletion are restored.`,
+	RunE: doRestoreProjects,
+	Example: `  Restore the project test1-db removed from test1 group
+
+  gitlab-api-client restore-projects test1-db \
+    --group test1 \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem`,
+}
+
+var (
+	restoreProjectsGroup   string
+	restoreProjectsProject string
+	restoreProjectsUser    string
+)
+
+func init() {
+	rootCmd.AddCommand(restoreProjectsCmd)
+	restoreProjectsCmd.Flags().StringVarP(&restoreProjectsGroup, "group", "g", "", "The full path of the group where projects will be restored")
+	restoreProjectsCmd.Flags().StringVarP(&restoreProjectsProject, "project", "p", "", "The pattern to match projects")
+	restoreProjectsCmd.Flags().StringVar(&restoreProjectsUser, "user", "", "The user whose namespace projects will be restored in without --group, as admin (default the current user)")
+}
+
+func doRestoreProjects(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 && restoreProjectsProject == "" {
+		return errors.Errorf("no project names specified")
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	projects, missing, err := selectNamespaceProjects(gitlabAPI, restoreProjectsGroup, restoreProjectsProject, restoreProjectsUser, args)
+	if err != nil {
+		return errors.Wrap(err, "selecting projects")
+	}
+	countEdit := 0
+	countNotEdit := 0
+	for _, name := range missing {
+		utils.PrintCSV([]string{name, "missing"})
+		countNotEdit++
+	}
+	for _, project := range projects {
+		if project.MarkedForDeletionAt == nil {
+			log.Infof("skipped gitlab project '%s' not marked for deletion", project.PathWithNamespace)
+			utils.PrintCSV([]string{project.PathWithNamespace, "not marked"})
+			countNotEdit++
+			continue
+		}
+		if err := gitlabAPI.RestoreProject(project); err != nil {
+			utils.PrintCSV([]string{project.PathWithNamespace, fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{project.PathWithNamespace, "restored"})
+			countEdit++
+		}
+	}
+	printTotals(len(projects)+len(missing), countEdit, countNotEdit)
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/cli"
@@ -30,7 +33,13 @@ var rootCmd = &cobra.Command{
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(version string) {
 	rootCmd.Version = version
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if traceHAR != nil {
+		if err := traceHAR.Write(traceHARFile); err != nil {
+			log.Error(err.Error())
+		}
+	}
+	if err != nil {
 		log.Error(err.Error())
 		os.Exit(1)
 	}
@@ -40,6 +49,20 @@ const (
 	trustedCertificates = "trusted-certificates"
 	gitlabAPIURL        = "gitlab.api-url"
 	gitlabPrivateToken  = "gitlab.private-token"
+	gitlabTokenFile     = "gitlab.private-token-file"
+	gitlabTokenHelper   = "gitlab.credential-helper"
+	gitlabTokenType     = "gitlab.token-type"
+	tokenStore          = "token-store"
+	clientCertificate   = "client-certificate"
+	clientKey           = "client-key"
+	tlsMinVersion       = "tls-min-version"
+	insecureSkipVerify  = "insecure-skip-verify"
+	proxy               = "proxy"
+	noProxy             = "no-proxy"
+	httpTimeout         = "http-timeout"
+	connectTimeout      = "connect-timeout"
+	// passphraseEnv is the environment variable of the token store passphrase
+	passphraseEnv = "GITLAB_API_CLIENT_PASSPHRASE"
 )
 
 var (
@@ -48,22 +71,47 @@ var (
 	logformat              string
 	logfile                string
 	debug                  bool
+	trace                  bool
+	traceBody              bool
+	traceHARFile           string
+	// traceHAR records the requests of every http client, written on exit
+	traceHAR *utils.HAR
 )
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPreRunE = applyProfileDefaults
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.api-client.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "v", false, "Print debug messages (includes info)")
-	rootCmd.PersistentFlags().StringArrayVar(&trustedCertificatesVal, trustedCertificates, []string{}, "PEM encoded trusted certificate chain")
+	rootCmd.PersistentFlags().StringArrayVar(&trustedCertificatesVal, trustedCertificates, []string{}, "PEM encoded trusted certificate chain (@file or @directory of PEM files)")
+	rootCmd.PersistentFlags().String(clientCertificate, "", "PEM encoded client certificate of mTLS (or @file)")
+	rootCmd.PersistentFlags().String(clientKey, "", "PEM encoded client certificate key of mTLS (or @file)")
+	rootCmd.PersistentFlags().String(tlsMinVersion, "", "The minimum TLS version (1.0, 1.1, 1.2 or 1.3)")
+	rootCmd.PersistentFlags().Bool(insecureSkipVerify, false, "Do not verify the server certificate (insecure, only for development)")
+	rootCmd.PersistentFlags().String(proxy, "", "The proxy url (default the HTTPS_PROXY and NO_PROXY environment)")
+	rootCmd.PersistentFlags().StringSlice(noProxy, []string{}, "The hosts, domains and networks (CIDR) not using --proxy, or the environment proxy")
+	rootCmd.PersistentFlags().Duration(httpTimeout, 0, "The time limit of every request (0 without limit)")
+	rootCmd.PersistentFlags().Duration(connectTimeout, 30*time.Second, "The time limit of the connection and TLS handshake")
 	rootCmd.PersistentFlags().StringVar(&logformat, "log-format", "dev", "Log format (json, log, dev, cli)")
 	rootCmd.PersistentFlags().StringVar(&logfile, "log-file", "", "Log file path (''=Stderr|'-'=Stdout)")
+	rootCmd.PersistentFlags().BoolVar(&trace, "trace", false, "Log the http requests with their status, latency and rate limits (includes info)")
+	rootCmd.PersistentFlags().BoolVar(&traceBody, "trace-body", false, "Log the http requests and responses bodies, with the secrets redacted (implies --trace)")
+	rootCmd.PersistentFlags().StringVar(&traceHARFile, "trace-har", "", "Save the http requests in this HAR file, with the secrets redacted")
 
 	rootCmd.PersistentFlags().StringP("api-url", "u", "https://gitlab.localhost/api/v4/", "Gitlab URL")
 	rootCmd.PersistentFlags().StringP("private-token", "t", "", "Your private token (grab it from https://gitlab.localhost/account)")
+	rootCmd.PersistentFlags().String("private-token-file", "", "The file with the token in the first line")
+	rootCmd.PersistentFlags().String("credential-helper", "", "The shell command printing the token, run with the get argument like git credential helpers")
+	rootCmd.PersistentFlags().String("token-type", gitlabapi.PrivateToken, "The token type (private, oauth or job)")
+	rootCmd.PersistentFlags().String(tokenStore, "", "The encrypted token store file of login (default is $HOME/.gitlab-api-client-tokens.json)")
+	rootCmd.PersistentFlags().String(profileKey, "", "The configuration profile of the instance settings")
 	viper.BindPFlags(rootCmd.PersistentFlags())
 
 	viper.BindPFlag(gitlabAPIURL, rootCmd.PersistentFlags().Lookup("api-url"))
 	viper.BindPFlag(gitlabPrivateToken, rootCmd.PersistentFlags().Lookup("private-token"))
+	viper.BindPFlag(gitlabTokenFile, rootCmd.PersistentFlags().Lookup("private-token-file"))
+	viper.BindPFlag(gitlabTokenHelper, rootCmd.PersistentFlags().Lookup("credential-helper"))
+	viper.BindPFlag(gitlabTokenType, rootCmd.PersistentFlags().Lookup("token-type"))
 }
 
 func initConfig() {
@@ -83,6 +131,7 @@ func initConfig() {
 	viper.SetEnvPrefix(rootCmd.Use)
 	viper.AutomaticEnv()
 	viper.ReadInConfig()
+	activeProfile = strings.ToLower(viper.GetString(profileKey))
 
 	log.SetHandler(cli.New(os.Stderr))
 	log.SetLevel(log.WarnLevel)
@@ -92,6 +141,10 @@ func initConfig() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	trace = trace || traceBody
+	if trace && !debug {
+		log.SetLevel(log.InfoLevel)
+	}
 }
 
 func gitlabAPI() (*gitlabapi.GitlabApi, error) {
@@ -99,23 +152,209 @@ func gitlabAPI() (*gitlabapi.GitlabApi, error) {
 	if err != nil {
 		return nil, err
 	}
-	return gitlabapi.NewGitlabApi(httpClient, viper.GetString(gitlabAPIURL), viper.GetString(gitlabPrivateToken)), nil
+	apiURL := viper.GetString(settingKey("api-url", gitlabAPIURL))
+	token, tokenType, err := resolveToken(apiURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving token")
+	}
+	return gitlabapi.NewGitlabApiToken(httpClient, apiURL, token, tokenType)
+}
+
+// tokenSources are the settings of the token sources, in the order they are used
+var tokenSources = []struct {
+	setting string
+	global  string
+}{
+	{"private-token", gitlabPrivateToken},
+	{"private-token-file", gitlabTokenFile},
+	{"credential-helper", gitlabTokenHelper},
+}
+
+func flagChanged(name string) bool {
+	flag := rootCmd.PersistentFlags().Lookup(name)
+	return flag != nil && flag.Changed
+}
+
+// readToken returns the token of the source setting value
+func readToken(setting, value, apiURL string) (string, error) {
+	switch setting {
+	case "private-token-file":
+		return utils.ReadTokenFile(value)
+	case "credential-helper":
+		return utils.CredentialHelper(value, apiURL)
+	}
+	return value, nil
+}
+
+// storedToken returns the token of the name in the login token store, if it has it
+func storedToken(name string) (string, bool, error) {
+	store, err := openTokenStore()
+	if err != nil {
+		return "", false, err
+	}
+	if !store.Has(name) {
+		return "", false, nil
+	}
+	if err := unlockTokenStore(store, false); err != nil {
+		return "", true, err
+	}
+	token, err := store.Get(name)
+	return token, true, err
+}
+
+// resolveToken returns the token, and its type, of the first source having it: the
+// private-token, the token file and the credential helper flags, the same sources
+// and the login token store of the active profile, and the same sources of the
+// gitlab section, the login token store and the CI_JOB_TOKEN environment variable.
+// The sources of the gitlab section are not used with the api url of the profile
+func resolveToken(apiURL string) (string, string, error) {
+	tokenType := viper.GetString(settingKey("token-type", gitlabTokenType))
+	for _, source := range tokenSources {
+		if flagChanged(source.setting) {
+			token, err := readToken(source.setting, viper.GetString(source.global), apiURL)
+			return token, tokenType, err
+		}
+	}
+	if activeProfile != "" && !flagChanged("api-url") {
+		prefix := profilesKey + "." + activeProfile + "."
+		for _, source := range tokenSources {
+			if value := viper.GetString(prefix + source.setting); value != "" {
+				token, err := readToken(source.setting, value, apiURL)
+				return token, tokenType, err
+			}
+		}
+		if token, ok, err := storedToken(activeProfile); ok || err != nil {
+			return token, tokenType, err
+		}
+		if viper.IsSet(prefix + "api-url") {
+			log.Debugf("no token in profile '%s', the gitlab section tokens are not used with its api url", activeProfile)
+			return "", tokenType, nil
+		}
+	}
+	for _, source := range tokenSources {
+		if value := viper.GetString(source.global); value != "" {
+			token, err := readToken(source.setting, value, apiURL)
+			return token, tokenType, err
+		}
+	}
+	if token, ok, err := storedToken(apiURL); ok || err != nil {
+		return token, tokenType, err
+	}
+	if token := os.Getenv("CI_JOB_TOKEN"); token != "" {
+		log.Debug("using the CI job token")
+		return token, gitlabapi.JobToken, nil
+	}
+	return "", tokenType, nil
+}
+
+func openTokenStore() (*utils.TokenStore, error) {
+	file := viper.GetString(tokenStore)
+	if file == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, "."+rootCmd.Use+"-tokens.json")
+	}
+	return utils.OpenTokenStore(file)
+}
+
+// tokenStoreName is the token store name of the token, the active profile, unless
+// --api-url is given, or the api url
+func tokenStoreName(apiURL string) string {
+	if activeProfile != "" && !flagChanged("api-url") {
+		return activeProfile
+	}
+	return apiURL
+}
+
+// unlockTokenStore unlocks the store with the passphrase of the environment or
+// asked, twice to confirm the new ones
+func unlockTokenStore(store *utils.TokenStore, confirm bool) error {
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		var err error
+		passphrase, err = utils.ReadSecret("Token store passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm {
+			repeated, err := utils.ReadSecret("Repeat the passphrase: ")
+			if err != nil {
+				return err
+			}
+			if repeated != passphrase {
+				return errors.New("the passphrases do not match")
+			}
+		}
+	}
+	if passphrase == "" {
+		return errors.New("empty token store passphrase")
+	}
+	return store.Unlock(passphrase)
+}
+
+// settingSlice returns the list setting, empty when it is not set
+func settingSlice(setting string) []string {
+	key := settingKey(setting, setting)
+	if !viper.IsSet(key) {
+		return []string{}
+	}
+	return viper.GetStringSlice(key)
+}
+
+// dereferenceSetting returns the setting value, or the file contents when it is @file
+func dereferenceSetting(setting string) ([]byte, error) {
+	value := viper.GetString(settingKey(setting, setting))
+	if value == "" {
+		return nil, nil
+	}
+	values, err := dereference([]string{value})
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
 }
 
 func httpClient() (*http.Client, error) {
-	if trustedCertificatesVal == nil || len(trustedCertificatesVal) == 0 {
-		trustedCertificatesVal = viper.GetStringSlice(trustedCertificates)
+	certificates := trustedCertificatesVal
+	if len(certificates) == 0 {
+		certificates = settingSlice(trustedCertificates)
 	}
 
-	certs, err := dereference(trustedCertificatesVal)
+	certs, err := dereference(certificates)
 	if err != nil {
 		return nil, err
 	}
+	opts := &utils.HTTPOptions{
+		TrustedCertificates: certs,
+		MinTLSVersion:       viper.GetString(settingKey(tlsMinVersion, tlsMinVersion)),
+		Insecure:            viper.GetBool(settingKey(insecureSkipVerify, insecureSkipVerify)),
+		Proxy:               viper.GetString(settingKey(proxy, proxy)),
+		NoProxy:             strings.Join(settingSlice(noProxy), ","),
+		Timeout:             viper.GetDuration(settingKey(httpTimeout, httpTimeout)),
+		ConnectTimeout:      viper.GetDuration(settingKey(connectTimeout, connectTimeout)),
+	}
+	if opts.ClientCertificate, err = dereferenceSetting(clientCertificate); err != nil {
+		return nil, err
+	}
+	if opts.ClientKey, err = dereferenceSetting(clientKey); err != nil {
+		return nil, err
+	}
+	if opts.Insecure {
+		log.Warn("the server certificate is not verified")
+	}
 
-	httpClient, err := utils.HTTPClient(certs)
+	httpClient, err := utils.NewHTTPClient(opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating http client")
 	}
+	if traceHARFile != "" && traceHAR == nil {
+		traceHAR = utils.NewHAR(rootCmd.Use, rootCmd.Version)
+	}
+	if trace || traceHAR != nil {
+		httpClient.Transport = &utils.TraceTransport{Next: httpClient.Transport, Body: traceBody, HAR: traceHAR}
+	}
 	return httpClient, nil
 }
 
@@ -123,14 +362,50 @@ func dereference(files []string) ([][]byte, error) {
 	res := [][]byte{}
 	for _, file := range files {
 		if file[0] == '@' {
-			bs, err := ioutil.ReadFile(file[1:len(file)])
+			bs, err := readReference(file[1:len(file)])
 			if err != nil {
 				return nil, errors.Wrapf(err, "trying to dereference '%s'", file)
 			}
-			res = append(res, bs)
+			res = append(res, bs...)
 		} else {
 			res = append(res, []byte(file))
 		}
 	}
 	return res, nil
 }
+
+// readReference returns the file contents, or the contents of the directory PEM files
+func readReference(file string) ([][]byte, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		bs, err := ioutil.ReadFile(file)
+		return [][]byte{bs}, err
+	}
+	entries, err := ioutil.ReadDir(file)
+	if err != nil {
+		return nil, err
+	}
+	res := [][]byte{}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".pem", ".crt", ".cer":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		bs, err := ioutil.ReadFile(filepath.Join(file, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, bs)
+	}
+	if len(res) == 0 {
+		return nil, errors.Errorf("no PEM files (.pem, .crt or .cer) in directory %q", file)
+	}
+	return res, nil
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// projectSelector holds the flags used by commands operating on a set of projects
+type projectSelector struct {
+	group   string
+	project string
+}
+
+func (s *projectSelector) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&s.group, "group", "g", "", "The pattern to match groups")
+	cmd.Flags().StringVarP(&s.project, "project", "p", "", "The pattern to match projects")
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.Trim(pattern, " ") == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compiling pattern '%s'", pattern)
+	}
+	return re, nil
+}
+
+// selectGroups returns the groups matching the selector group pattern
+func (s *projectSelector) selectGroups(helper *gitlabapi.GitlabApi) ([]*gitlab.Group, error) {
+	gitlabGroupRegexp, err := compilePattern(s.group)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := helper.ListGroups(&gitlab.ListGroupsOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing groups")
+	}
+	all := make([]*gitlab.Group, 0)
+	for _, group := range groups {
+		if gitlabGroupRegexp != nil && !gitlabGroupRegexp.MatchString(group.Name) {
+			log.Debugf("skipped gitlab group '%s' not matching '%s'", group.Name, s.group)
+			continue
+		}
+		all = append(all, group)
+	}
+	return all, nil
+}
+
+// selectProjects returns the projects of every group matching the selector patterns
+func (s *projectSelector) selectProjects(helper *gitlabapi.GitlabApi) ([]*gitlab.Project, error) {
+	gitlabProjectRegexp, err := compilePattern(s.project)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.selectGroups(helper)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]*gitlab.Project, 0)
+	for _, group := range groups {
+		projects, err := helper.ListGroupProjects(group)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing group projects")
+		}
+		for _, project := range projects {
+			if gitlabProjectRegexp != nil && !gitlabProjectRegexp.MatchString(project.Name) {
+				log.Debugf("skipped gitlab project '%s' not matching '%s'", project.Name, s.project)
+				continue
+			}
+			all = append(all, project)
+		}
+	}
+	return all, nil
+}
+
+// owner is a project or a group, the two scopes of tokens and members
+type owner struct {
+	project *gitlab.Project
+	group   *gitlab.Group
+}
+
+func (o *owner) scope() string {
+	if o.group != nil {
+		return "group"
+	}
+	return "project"
+}
+
+func (o *owner) path() string {
+	if o.group != nil {
+		return o.group.FullPath
+	}
+	return o.project.PathWithNamespace
+}
+
+// selectOwners returns the selected groups or projects depending on the scope
+func (s *projectSelector) selectOwners(helper *gitlabapi.GitlabApi, scope string) ([]*owner, error) {
+	owners := make([]*owner, 0)
+	switch scope {
+	case "project":
+		projects, err := s.selectProjects(helper)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			owners = append(owners, &owner{project: project})
+		}
+	case "group":
+		groups, err := s.selectGroups(helper)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			owners = append(owners, &owner{group: group})
+		}
+	default:
+		return nil, errors.Errorf("unknown scope: %s (project or group)", scope)
+	}
+	return owners, nil
+}
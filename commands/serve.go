@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+const (
+	serveListen      = "serve.listen"
+	serveSecretToken = "serve.secret-token"
+	serveActions     = "serve.actions"
+)
+
+// maxHookSize is the maximum size of the hook payloads
+const maxHookSize = 10 << 20
+
+var serveDryRun bool
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receive Gitlab system and project hooks and run actions",
+	Long: `Receive Gitlab system and project hooks and run actions
+
+  Every request must have the X-Gitlab-Token header with the secret token.
+  The actions for each event are read from the serve.actions configuration:
+
+    serve:
+      listen: ":8080"
+      secret-token: REPLACE
+      actions:
+        - event: project_create
+          action: enable-deploy-key
+          deploy-key-id: 12
+        - event: project_create
+          action: add-members
+          usernames: [user1, userN]
+          access-level: 30
+        - event: project_create
+          action: protect-default-branch
+          push-access-level: 40
+          merge-access-level: 30
+
+  The actions are enable-deploy-key, add-members and protect-default-branch.`,
+	RunE: doServe,
+	Example: `  Run the server and post a recorded event
+
+  gitlab-api-client serve --config ./api-client.yaml --dry-run
+
+  curl -X POST -H 'X-Gitlab-Token: REPLACE' \
+    --data @docs/hooks/project_create.json http://localhost:8080/`,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("listen", ":8080", "The address to listen on")
+	serveCmd.Flags().String("secret-token", "", "The secret token expected in X-Gitlab-Token")
+	serveCmd.Flags().BoolVar(&serveDryRun, "dry-run", false, "Log the actions of each event without running them")
+	viper.BindPFlag(serveListen, serveCmd.Flags().Lookup("listen"))
+	viper.BindPFlag(serveSecretToken, serveCmd.Flags().Lookup("secret-token"))
+}
+
+// serveAction is an action run when an event is received
+type serveAction struct {
+	Event            string   `mapstructure:"event" json:"event"`
+	Action           string   `mapstructure:"action" json:"action"`
+	DeployKeyID      int      `mapstructure:"deploy-key-id" json:"-"`
+	Usernames        []string `mapstructure:"usernames" json:"-"`
+	AccessLevel      int      `mapstructure:"access-level" json:"-"`
+	Branch           string   `mapstructure:"branch" json:"-"`
+	PushAccessLevel  int      `mapstructure:"push-access-level" json:"-"`
+	MergeAccessLevel int      `mapstructure:"merge-access-level" json:"-"`
+}
+
+func (a *serveAction) validate() error {
+	switch a.Action {
+	case "enable-deploy-key":
+		if a.DeployKeyID == 0 {
+			return errors.Errorf("action %q on %q without deploy-key-id", a.Action, a.Event)
+		}
+	case "add-members":
+		if len(a.Usernames) == 0 {
+			return errors.Errorf("action %q on %q without usernames", a.Action, a.Event)
+		}
+		if a.AccessLevel == 0 {
+			a.AccessLevel = int(gitlab.ReporterPermissions)
+		}
+	case "protect-default-branch":
+		if a.PushAccessLevel == 0 {
+			a.PushAccessLevel = int(gitlab.MaintainerPermissions)
+		}
+		if a.MergeAccessLevel == 0 {
+			a.MergeAccessLevel = int(gitlab.MaintainerPermissions)
+		}
+	default:
+		return errors.Errorf("unknown action %q on %q", a.Action, a.Event)
+	}
+	return nil
+}
+
+func (a *serveAction) run(helper *gitlabapi.GitlabApi, project *gitlab.Project) error {
+	switch a.Action {
+	case "enable-deploy-key":
+		return helper.EnableProjectDeployKey(project, a.DeployKeyID)
+	case "add-members":
+		users := make([]*gitlab.User, 0)
+		for _, username := range a.Usernames {
+			user, err := helper.GetUser(username)
+			if err != nil {
+				return errors.Wrap(err, "getting user")
+			}
+			users = append(users, user)
+		}
+		return helper.AddMembers(project, gitlab.AccessLevel(gitlab.AccessLevelValue(a.AccessLevel)), users...)
+	case "protect-default-branch":
+		branch := a.Branch
+		if branch == "" {
+			branch = project.DefaultBranch
+		}
+		if branch == "" {
+			branch = "master"
+		}
+		return helper.ProtectBranch(project, branch, gitlab.AccessLevelValue(a.PushAccessLevel), gitlab.AccessLevelValue(a.MergeAccessLevel))
+	}
+	return errors.Errorf("unknown action %q", a.Action)
+}
+
+// hookEvent holds the fields shared by system and project hooks payloads
+type hookEvent struct {
+	EventName  string `json:"event_name"`
+	ObjectKind string `json:"object_kind"`
+	ProjectID  int    `json:"project_id"`
+	Project    *struct {
+		ID int `json:"id"`
+	} `json:"project"`
+}
+
+func (e *hookEvent) name() string {
+	if e.EventName != "" {
+		return e.EventName
+	}
+	return e.ObjectKind
+}
+
+func (e *hookEvent) projectID() int {
+	if e.ProjectID != 0 {
+		return e.ProjectID
+	}
+	if e.Project != nil {
+		return e.Project.ID
+	}
+	return 0
+}
+
+type actionResult struct {
+	Action string `json:"action"`
+	Status string `json:"status"`
+}
+
+// hookHandler verifies the received hooks and dispatches them to the actions of the event
+type hookHandler struct {
+	helper  *gitlabapi.GitlabApi
+	token   string
+	actions []*serveAction
+	dryRun  bool
+}
+
+func (h *hookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(h.token)) != 1 {
+		log.Warnf("rejected hook from %s: invalid token", r.RemoteAddr)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	event := &hookEvent{}
+	r.Body = http.MaxBytesReader(w, r.Body, maxHookSize)
+	if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	results := h.dispatch(event)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func (h *hookHandler) dispatch(event *hookEvent) []*actionResult {
+	ctx := log.WithFields(log.Fields{"event": event.name(), "project": event.projectID()})
+	ctx.Info("received hook")
+	results := make([]*actionResult, 0)
+	var project *gitlab.Project
+	for _, action := range h.actions {
+		if action.Event != event.name() {
+			continue
+		}
+		result := &actionResult{Action: action.Action, Status: "ok"}
+		results = append(results, result)
+		if event.projectID() == 0 {
+			result.Status = "Fail event without project"
+			continue
+		}
+		if h.dryRun {
+			ctx.Infof("dry run action %q", action.Action)
+			result.Status = "dry-run"
+			continue
+		}
+		if project == nil {
+			var err error
+			project, err = h.helper.GetProject(event.projectID())
+			if err != nil {
+				ctx.Errorf("getting project: %v", err)
+				result.Status = fmt.Sprintf("Fail %v", err)
+				continue
+			}
+		}
+		if err := action.run(h.helper, project); err != nil {
+			ctx.Errorf("action %q: %v", action.Action, err)
+			result.Status = fmt.Sprintf("Fail %v", err)
+			continue
+		}
+		ctx.Infof("action %q done on project %q", action.Action, project.PathWithNamespace)
+	}
+	return results
+}
+
+func doServe(cmd *cobra.Command, args []string) error {
+	token := viper.GetString(serveSecretToken)
+	if token == "" {
+		return errors.Errorf("no secret token specified (%s)", serveSecretToken)
+	}
+	actions := make([]*serveAction, 0)
+	if err := viper.UnmarshalKey(serveActions, &actions); err != nil {
+		return errors.Wrapf(err, "reading %s configuration", serveActions)
+	}
+	for _, action := range actions {
+		if err := action.validate(); err != nil {
+			return errors.Wrap(err, "invalid action")
+		}
+	}
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	handler := &hookHandler{
+		helper:  gitlabAPI,
+		token:   token,
+		actions: actions,
+		dryRun:  serveDryRun,
+	}
+	listen := viper.GetString(serveListen)
+	log.Infof("listening on %s with %d actions", listen, len(actions))
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// the actions run before the response is written
+		WriteTimeout: 5 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	gitlabapi "github.com/janusky/gitlab-api-client/gitlab"
+	"github.com/janusky/gitlab-api-client/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+var (
+	transferSelector projectSelector
+	transferTo       string
+	transferNewPath  string
+	transferNewName  string
+	transferFrom     string
+	transferDryRun   bool
+)
+
+var transferCmd = &cobra.Command{
+	Use:   "transfer",
+	Short: "Move projects to another group, optionally renaming them",
+	Long: `Move projects to another group, optionally renaming them
+
+  The selected projects are moved to the --to group, and a single project can
+  be renamed with --new-path and --new-name. The --from file has CSV rows
+  (old_path,new_path,new_name) with the full paths of every project and the
+  optional new name.
+
+  The projects are moved before renamed, so the target groups must exist and
+  have no project with their current or new path or name. The report has the
+  old and new URLs of every project, since Gitlab redirects the old ones until
+  they are reused.`,
+	Example: `  Move the test1 group projects to the platform/infra group
+
+  gitlab-api-client transfer \
+    --group test1 \
+    --to platform/infra \
+    --api-url https://gitlab.localhost/api/v4/ \
+    --private-token token \
+    --trusted-certificates @certificates.pem
+
+  Move and rename the projects of moves.csv
+
+  gitlab-api-client transfer --from moves.csv --dry-run`,
+	RunE: doTransfer,
+}
+
+func init() {
+	rootCmd.AddCommand(transferCmd)
+	transferSelector.addFlags(transferCmd)
+	transferCmd.Flags().StringVar(&transferTo, "to", "", "The group full path the projects are moved to")
+	transferCmd.Flags().StringVar(&transferNewPath, "new-path", "", "The new path of the project")
+	transferCmd.Flags().StringVar(&transferNewName, "new-name", "", "The new name of the project")
+	transferCmd.Flags().StringVarP(&transferFrom, "from", "f", "", "CSV input file (old_path,new_path,new_name) to read moves from")
+	transferCmd.Flags().BoolVar(&transferDryRun, "dry-run", false, "Check and print the moves without applying them")
+}
+
+// transferMove is the move of a project to a group with a path and name
+type transferMove struct {
+	oldPath   string
+	project   *gitlab.Project
+	namespace string
+	path      string
+	name      string
+	err       error
+}
+
+func (m *transferMove) newPath() string {
+	return m.namespace + "/" + m.path
+}
+
+// newURL returns the web url of the project after the move, or its new path when
+// the project is unknown
+func (m *transferMove) newURL() string {
+	if m.project == nil || !strings.HasSuffix(m.project.WebURL, m.project.PathWithNamespace) {
+		return m.newPath()
+	}
+	return strings.TrimSuffix(m.project.WebURL, m.project.PathWithNamespace) + m.newPath()
+}
+
+func readTransferMoves(helper *gitlabapi.GitlabApi) ([]*transferMove, error) {
+	f, err := os.Open(transferFrom)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening input file %q", transferFrom)
+	}
+	defer f.Close()
+	moves := make([]*transferMove, 0)
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing csv data from file %q", transferFrom)
+		}
+		for len(rec) < 3 {
+			rec = append(rec, "")
+		}
+		oldPath := strings.Trim(strings.TrimSpace(rec[0]), "/")
+		newPath := strings.Trim(strings.TrimSpace(rec[1]), "/")
+		move := &transferMove{oldPath: oldPath, namespace: path.Dir(newPath), path: path.Base(newPath), name: strings.TrimSpace(rec[2])}
+		moves = append(moves, move)
+		if oldPath == "" || !strings.Contains(newPath, "/") {
+			move.err = errors.Errorf("invalid move %q to %q", oldPath, newPath)
+			continue
+		}
+		move.project, move.err = helper.FindProject(oldPath)
+		if move.err == nil && move.project == nil {
+			move.err = errors.Errorf("project %q not found", oldPath)
+		}
+	}
+	return moves, nil
+}
+
+func selectTransferMoves(helper *gitlabapi.GitlabApi) ([]*transferMove, error) {
+	if transferTo == "" {
+		return nil, errors.New("no target group specified: use --to or --from")
+	}
+	projects, err := transferSelector.selectProjects(helper)
+	if err != nil {
+		return nil, errors.Wrap(err, "selecting projects")
+	}
+	if (transferNewPath != "" || transferNewName != "") && len(projects) != 1 {
+		return nil, errors.Errorf("--new-path and --new-name need a single project, %d selected", len(projects))
+	}
+	moves := make([]*transferMove, 0, len(projects))
+	for _, project := range projects {
+		move := &transferMove{
+			oldPath:   project.PathWithNamespace,
+			project:   project,
+			namespace: strings.Trim(transferTo, "/"),
+			path:      project.Path,
+			name:      transferNewName,
+		}
+		if transferNewPath != "" {
+			move.path = transferNewPath
+		}
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
+
+func (m *transferMove) newName() string {
+	if m.name != "" {
+		return m.name
+	}
+	return m.project.Name
+}
+
+// transferTarget is a target group of the moves and its projects
+type transferTarget struct {
+	group    *gitlab.Group
+	projects []*gitlab.Project
+	err      error
+}
+
+func findTransferTarget(helper *gitlabapi.GitlabApi, namespace string) *transferTarget {
+	target := &transferTarget{}
+	target.group, target.err = helper.FindGroup(namespace)
+	if target.err != nil {
+		return target
+	}
+	if target.group == nil {
+		target.err = errors.Errorf("target group %q not found", namespace)
+		return target
+	}
+	target.projects, target.err = helper.ListGroupProjects(target.group)
+	return target
+}
+
+// collision returns the target project with the path or name of the moved project,
+// before and after its rename, since it is transferred before renamed
+func (t *transferTarget) collision(move *transferMove) *gitlab.Project {
+	for _, project := range t.projects {
+		if project.ID == move.project.ID {
+			continue
+		}
+		if strings.EqualFold(project.Path, move.path) || strings.EqualFold(project.Path, move.project.Path) ||
+			project.Name == move.newName() || project.Name == move.project.Name {
+			return project
+		}
+	}
+	return nil
+}
+
+// checkTransferMoves sets the error of the moves to missing groups or colliding paths and names
+func checkTransferMoves(helper *gitlabapi.GitlabApi, moves []*transferMove) {
+	targets := make(map[string]*transferTarget)
+	claimedPaths := make(map[string]string)
+	claimedNames := make(map[string]string)
+	for _, move := range moves {
+		if move.err != nil {
+			continue
+		}
+		target, ok := targets[move.namespace]
+		if !ok {
+			target = findTransferTarget(helper, move.namespace)
+			targets[move.namespace] = target
+		}
+		if target.err != nil {
+			move.err = target.err
+			continue
+		}
+		if project := target.collision(move); project != nil {
+			move.err = errors.Errorf("collision with project %q", project.PathWithNamespace)
+			continue
+		}
+		pathKey := strings.ToLower(move.newPath())
+		nameKey := move.namespace + "/" + move.newName()
+		if other, ok := claimedPaths[pathKey]; ok {
+			move.err = errors.Errorf("collision with the move of %q", other)
+			continue
+		}
+		if other, ok := claimedNames[nameKey]; ok {
+			move.err = errors.Errorf("collision with the name of the move of %q", other)
+			continue
+		}
+		claimedPaths[pathKey] = move.oldPath
+		claimedNames[nameKey] = move.oldPath
+	}
+}
+
+// applyTransferMove moves the project and renames it, when the path or name change
+func applyTransferMove(helper *gitlabapi.GitlabApi, move *transferMove) (*gitlab.Project, error) {
+	project := move.project
+	var err error
+	if project.Namespace == nil || project.Namespace.FullPath != move.namespace {
+		project, err = helper.TransferProject(project, move.namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	newPath, newName := "", ""
+	if move.path != project.Path {
+		newPath = move.path
+	}
+	if move.name != "" && move.name != project.Name {
+		newName = move.name
+	}
+	if newPath == "" && newName == "" {
+		return project, nil
+	}
+	renamed, err := helper.RenameProject(project, newPath, newName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "project moved to %q", project.PathWithNamespace)
+	}
+	return renamed, nil
+}
+
+func doTransfer(cmd *cobra.Command, args []string) error {
+	gitlabAPI, err := gitlabAPI()
+	if err != nil {
+		return errors.Wrap(err, "creating gitlab api")
+	}
+	var moves []*transferMove
+	if transferFrom != "" {
+		moves, err = readTransferMoves(gitlabAPI)
+	} else {
+		moves, err = selectTransferMoves(gitlabAPI)
+	}
+	if err != nil {
+		return err
+	}
+	checkTransferMoves(gitlabAPI, moves)
+	countEdit := 0
+	countNotEdit := 0
+	for _, move := range moves {
+		oldURL := move.oldPath
+		if move.project != nil {
+			oldURL = move.project.WebURL
+		}
+		if move.err != nil {
+			utils.PrintCSV([]string{oldURL, move.newURL(), fmt.Sprintf("Fail %v", move.err)})
+			countNotEdit++
+			continue
+		}
+		if move.project.PathWithNamespace == move.newPath() && (move.name == "" || move.name == move.project.Name) {
+			utils.PrintCSV([]string{oldURL, oldURL, "unchanged"})
+			countNotEdit++
+			continue
+		}
+		if transferDryRun {
+			utils.PrintCSV([]string{oldURL, move.newURL(), "planned"})
+			countEdit++
+			continue
+		}
+		project, err := applyTransferMove(gitlabAPI, move)
+		if err != nil {
+			utils.PrintCSV([]string{oldURL, move.newURL(), fmt.Sprintf("Fail %v", err)})
+			countNotEdit++
+		} else {
+			utils.PrintCSV([]string{oldURL, project.WebURL, "ok"})
+			countEdit++
+		}
+	}
+	printTotals(len(moves), countEdit, countNotEdit)
+	return nil
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// AccessToken is a project or group access token
+//
+// GitLab API docs: https://docs.gitlab.com/ee/api/resource_access_tokens.html
+type AccessToken struct {
+	ID          int                     `json:"id"`
+	UserID      int                     `json:"user_id"`
+	Name        string                  `json:"name"`
+	Scopes      []string                `json:"scopes"`
+	AccessLevel gitlab.AccessLevelValue `json:"access_level"`
+	CreatedAt   *time.Time              `json:"created_at"`
+	ExpiresAt   *gitlab.ISOTime         `json:"expires_at"`
+	Active      bool                    `json:"active"`
+	Revoked     bool                    `json:"revoked"`
+	Token       string                  `json:"token,omitempty"`
+}
+
+// CreateAccessTokenOptions are the options to create a project or group access token
+type CreateAccessTokenOptions struct {
+	Name        *string                  `url:"name,omitempty" json:"name,omitempty"`
+	Scopes      []string                 `url:"scopes,omitempty" json:"scopes,omitempty"`
+	AccessLevel *gitlab.AccessLevelValue `url:"access_level,omitempty" json:"access_level,omitempty"`
+	ExpiresAt   *gitlab.ISOTime          `url:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+func projectResource(project *gitlab.Project) string {
+	return fmt.Sprintf("projects/%d", project.ID)
+}
+
+func groupResource(group *gitlab.Group) string {
+	return fmt.Sprintf("groups/%d", group.ID)
+}
+
+// listAccessTokens lists the access tokens of the resource (projects/:id or groups/:id)
+func (h *GitlabApi) listAccessTokens(resource string) ([]*AccessToken, error) {
+	opts := &gitlab.ListOptions{Page: 1}
+	all := make([]*AccessToken, 0)
+	for {
+		req, err := h.Client.NewRequest("GET", resource+"/access_tokens", opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		var tokens []*AccessToken
+		resp, err := h.Client.Do(req, &tokens)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing %s access tokens", resource)
+		}
+		all = append(all, tokens...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) createAccessToken(resource string, opts *CreateAccessTokenOptions) (*AccessToken, error) {
+	req, err := h.Client.NewRequest("POST", resource+"/access_tokens", opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := new(AccessToken)
+	resp, err := h.Client.Do(req, token)
+	if err := checkResponse(resp, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "creating %s access token %q", resource, *opts.Name)
+	}
+	return token, nil
+}
+
+func (h *GitlabApi) revokeAccessToken(resource string, token *AccessToken) error {
+	req, err := h.Client.NewRequest("DELETE", fmt.Sprintf("%s/access_tokens/%d", resource, token.ID), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.Client.Do(req, nil)
+	if err := checkResponse(resp, err, is2xx); err != nil {
+		return errors.Wrapf(err, "revoking %s access token (%d %s)", resource, token.ID, token.Name)
+	}
+	return nil
+}
+
+func (h *GitlabApi) ListProjectAccessTokens(project *gitlab.Project) ([]*AccessToken, error) {
+	return h.listAccessTokens(projectResource(project))
+}
+
+func (h *GitlabApi) ListGroupAccessTokens(group *gitlab.Group) ([]*AccessToken, error) {
+	return h.listAccessTokens(groupResource(group))
+}
+
+func (h *GitlabApi) CreateProjectAccessToken(project *gitlab.Project, opts *CreateAccessTokenOptions) (*AccessToken, error) {
+	return h.createAccessToken(projectResource(project), opts)
+}
+
+func (h *GitlabApi) CreateGroupAccessToken(group *gitlab.Group, opts *CreateAccessTokenOptions) (*AccessToken, error) {
+	return h.createAccessToken(groupResource(group), opts)
+}
+
+func (h *GitlabApi) RevokeProjectAccessToken(project *gitlab.Project, token *AccessToken) error {
+	return h.revokeAccessToken(projectResource(project), token)
+}
+
+func (h *GitlabApi) RevokeGroupAccessToken(group *gitlab.Group, token *AccessToken) error {
+	return h.revokeAccessToken(groupResource(group), token)
+}
+
+// SetProjectVariable creates the project CI/CD variable, or updates its value when it exists
+func (h *GitlabApi) SetProjectVariable(project *gitlab.Project, key, value string, masked bool) error {
+	_, res, err := h.Client.ProjectVariables.GetVariable(project.ID, key)
+	if is404(res) {
+		_, res, err := h.Client.ProjectVariables.CreateVariable(project.ID, &gitlab.CreateProjectVariableOptions{
+			Key:    &key,
+			Value:  &value,
+			Masked: &masked,
+		})
+		if err := checkResponse(res, err, is2xx); err != nil {
+			return errors.Wrapf(err, "creating variable %q in project %q", key, project.PathWithNamespace)
+		}
+		return nil
+	}
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "getting variable %q in project %q", key, project.PathWithNamespace)
+	}
+	_, res, err = h.Client.ProjectVariables.UpdateVariable(project.ID, key, &gitlab.UpdateProjectVariableOptions{
+		Value:  &value,
+		Masked: &masked,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "updating variable %q in project %q", key, project.PathWithNamespace)
+	}
+	return nil
+}
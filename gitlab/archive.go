@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func (h *GitlabApi) ArchiveProject(project *gitlab.Project) error {
+	_, res, err := h.Client.Projects.ArchiveProject(project.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "archiving project %q", project.PathWithNamespace)
+	}
+	return nil
+}
+
+func (h *GitlabApi) UnarchiveProject(project *gitlab.Project) error {
+	_, res, err := h.Client.Projects.UnarchiveProject(project.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "unarchiving project %q", project.PathWithNamespace)
+	}
+	return nil
+}
+
+// HasOpenMergeRequests reports if the project has opened merge requests
+func (h *GitlabApi) HasOpenMergeRequests(project *gitlab.Project) (bool, error) {
+	state := "opened"
+	mrs, res, err := h.Client.MergeRequests.ListProjectMergeRequests(project.ID, &gitlab.ListProjectMergeRequestsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 1},
+		State:       &state,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return false, errors.Wrapf(err, "listing project %q merge requests", project.PathWithNamespace)
+	}
+	return len(mrs) > 0, nil
+}
+
+// HasPipelinesSince reports if the project has pipelines updated after the time
+func (h *GitlabApi) HasPipelinesSince(project *gitlab.Project, since time.Time) (bool, error) {
+	pipelines, res, err := h.Client.Pipelines.ListProjectPipelines(project.ID, &gitlab.ListProjectPipelinesOptions{
+		ListOptions:  gitlab.ListOptions{PerPage: 1},
+		UpdatedAfter: &since,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return false, errors.Wrapf(err, "listing project %q pipelines", project.PathWithNamespace)
+	}
+	return len(pipelines) > 0, nil
+}
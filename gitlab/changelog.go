@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// Changes are the merged merge requests and the commits outside of them between two refs
+type Changes struct {
+	From          string
+	To            string
+	Commits       []*gitlab.Commit
+	MergeRequests []*gitlab.MergeRequest
+}
+
+func (h *GitlabApi) Compare(project *gitlab.Project, from, to string) (*gitlab.Compare, error) {
+	compare, res, err := h.Client.Repositories.Compare(project.ID, &gitlab.CompareOptions{
+		From: &from,
+		To:   &to,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "comparing %q and %q in project %q", from, to, project.PathWithNamespace)
+	}
+	return compare, nil
+}
+
+func (h *GitlabApi) ListMergedMergeRequests(project *gitlab.Project, opts *gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, error) {
+	state := "merged"
+	opts.State = &state
+	opts.Page = 1
+	all := make([]*gitlab.MergeRequest, 0)
+	for {
+		mrs, resp, err := h.Client.MergeRequests.ListProjectMergeRequests(project.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrap(err, "listing project merge requests")
+		}
+		all = append(all, mrs...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) ListMergeRequestCommits(project *gitlab.Project, mr *gitlab.MergeRequest) ([]*gitlab.Commit, error) {
+	opts := &gitlab.GetMergeRequestCommitsOptions{Page: 1}
+	all := make([]*gitlab.Commit, 0)
+	for {
+		commits, resp, err := h.Client.MergeRequests.GetMergeRequestCommits(project.ID, mr.IID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing merge request !%d commits", mr.IID)
+		}
+		all = append(all, commits...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+// ListChanges returns the merged merge requests whose commits are between the refs,
+// and the remaining commits that are not merge commits
+func (h *GitlabApi) ListChanges(project *gitlab.Project, from, to string) (*Changes, error) {
+	compare, err := h.Compare(project, from, to)
+	if err != nil {
+		return nil, err
+	}
+	shas := make(map[string]bool)
+	for _, commit := range compare.Commits {
+		shas[commit.ID] = true
+	}
+	opts := &gitlab.ListProjectMergeRequestsOptions{}
+	fromCommit, res, err := h.Client.Commits.GetCommit(project.ID, from)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting commit %q in project %q", from, project.PathWithNamespace)
+	}
+	if fromCommit.CommittedDate != nil {
+		opts.UpdatedAfter = fromCommit.CommittedDate
+	}
+	mrs, err := h.ListMergedMergeRequests(project, opts)
+	if err != nil {
+		return nil, err
+	}
+	changes := &Changes{
+		From:          from,
+		To:            to,
+		Commits:       make([]*gitlab.Commit, 0),
+		MergeRequests: make([]*gitlab.MergeRequest, 0),
+	}
+	covered := make(map[string]bool)
+	for _, mr := range mrs {
+		if !shas[mr.MergeCommitSHA] && !shas[mr.SquashCommitSHA] && !shas[mr.SHA] {
+			continue
+		}
+		changes.MergeRequests = append(changes.MergeRequests, mr)
+		covered[mr.MergeCommitSHA] = true
+		covered[mr.SquashCommitSHA] = true
+		commits, err := h.ListMergeRequestCommits(project, mr)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range commits {
+			covered[commit.ID] = true
+		}
+	}
+	for _, commit := range compare.Commits {
+		if covered[commit.ID] || len(commit.ParentIDs) > 1 {
+			continue
+		}
+		changes.Commits = append(changes.Commits, commit)
+	}
+	return changes, nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// GetProjectStatistics returns the project with its statistics, like the storage size
+func (h *GitlabApi) GetProjectStatistics(project *gitlab.Project) (*gitlab.Project, error) {
+	statistics := true
+	p, res, err := h.Client.Projects.GetProject(project.ID, &gitlab.GetProjectOptions{Statistics: &statistics})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting project %q statistics", project.PathWithNamespace)
+	}
+	return p, nil
+}
+
+// DeletionAdjournedPeriod returns the days Gitlab waits to delete projects marked for
+// deletion, 0 when the settings are not readable (admin only)
+func (h *GitlabApi) DeletionAdjournedPeriod() int {
+	req, err := h.Client.NewRequest("GET", "application/settings", nil, nil)
+	if err != nil {
+		return 0
+	}
+	var settings struct {
+		DeletionAdjournedPeriod int `json:"deletion_adjourned_period"`
+	}
+	res, err := h.Client.Do(req, &settings)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return 0
+	}
+	return settings.DeletionAdjournedPeriod
+}
+
+// DeleteProject deletes the project and returns the status: removed, or scheduled with
+// the deletion date when Gitlab delays the deletion, which is unknown without the
+// adjourned period of the settings (admin only)
+func (h *GitlabApi) DeleteProject(project *gitlab.Project, adjournedPeriod int) (string, error) {
+	res, err := h.Client.Projects.DeleteProject(project.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return "", errors.Wrapf(err, "removing project %q (%d)", project.PathWithNamespace, project.ID)
+	}
+	p, err := h.FindProject(project.ID)
+	if err != nil {
+		return "", err
+	}
+	if p == nil || p.MarkedForDeletionAt == nil {
+		return "removed", nil
+	}
+	marked := time.Time(*p.MarkedForDeletionAt)
+	if adjournedPeriod <= 0 {
+		return fmt.Sprintf("scheduled for deletion on an unknown date, marked on %s (the deletion date needs admin)", marked.Format("2006-01-02")), nil
+	}
+	return fmt.Sprintf("scheduled for deletion on %s", marked.AddDate(0, 0, adjournedPeriod).Format("2006-01-02")), nil
+}
+
+// RestoreProject restores a project marked for deletion
+//
+// GitLab API docs: https://docs.gitlab.com/ee/api/projects.html#restore-project-marked-for-deletion
+func (h *GitlabApi) RestoreProject(project *gitlab.Project) error {
+	req, err := h.Client.NewRequest("POST", fmt.Sprintf("projects/%d/restore", project.ID), nil, nil)
+	if err != nil {
+		return err
+	}
+	res, err := h.Client.Do(req, nil)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "restoring project %q (%d)", project.PathWithNamespace, project.ID)
+	}
+	return nil
+}
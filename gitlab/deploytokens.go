@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func (h *GitlabApi) ListProjectDeployTokens(project *gitlab.Project) ([]*gitlab.DeployToken, error) {
+	opts := &gitlab.ListProjectDeployTokensOptions{Page: 1}
+	all := make([]*gitlab.DeployToken, 0)
+	for {
+		tokens, resp, err := h.Client.DeployTokens.ListProjectDeployTokens(project.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing project %q deploy tokens", project.PathWithNamespace)
+		}
+		all = append(all, tokens...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) ListGroupDeployTokens(group *gitlab.Group) ([]*gitlab.DeployToken, error) {
+	opts := &gitlab.ListGroupDeployTokensOptions{Page: 1}
+	all := make([]*gitlab.DeployToken, 0)
+	for {
+		tokens, resp, err := h.Client.DeployTokens.ListGroupDeployTokens(group.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing group %q deploy tokens", group.FullPath)
+		}
+		all = append(all, tokens...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) CreateProjectDeployToken(project *gitlab.Project, opts *gitlab.CreateProjectDeployTokenOptions) (*gitlab.DeployToken, error) {
+	token, res, err := h.Client.DeployTokens.CreateProjectDeployToken(project.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "creating deploy token %q in project %q", *opts.Name, project.PathWithNamespace)
+	}
+	return token, nil
+}
+
+func (h *GitlabApi) CreateGroupDeployToken(group *gitlab.Group, opts *gitlab.CreateGroupDeployTokenOptions) (*gitlab.DeployToken, error) {
+	token, res, err := h.Client.DeployTokens.CreateGroupDeployToken(group.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "creating deploy token %q in group %q", *opts.Name, group.FullPath)
+	}
+	return token, nil
+}
+
+func (h *GitlabApi) DeleteProjectDeployToken(project *gitlab.Project, token *gitlab.DeployToken) error {
+	res, err := h.Client.DeployTokens.DeleteProjectDeployToken(project.ID, token.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "deleting deploy token (%d %s) in project %q", token.ID, token.Name, project.PathWithNamespace)
+	}
+	return nil
+}
+
+func (h *GitlabApi) DeleteGroupDeployToken(group *gitlab.Group, token *gitlab.DeployToken) error {
+	res, err := h.Client.DeployTokens.DeleteGroupDeployToken(group.ID, token.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "deleting deploy token (%d %s) in group %q", token.ID, token.Name, group.FullPath)
+	}
+	return nil
+}
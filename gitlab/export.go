@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apex/log"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// exportPollInterval is the time between the export status checks
+var exportPollInterval = 5 * time.Second
+
+// ExportProject schedules the project export, waits for it up to the timeout and
+// returns the exported file contents
+//
+// GitLab API docs: https://docs.gitlab.com/ee/api/project_import_export.html
+func (h *GitlabApi) ExportProject(project *gitlab.Project, timeout time.Duration) ([]byte, error) {
+	res, err := h.Client.ProjectImportExport.ScheduleExport(project.ID, &gitlab.ScheduleExportOptions{})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "scheduling project %q export", project.PathWithNamespace)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		status, res, err := h.Client.ProjectImportExport.ExportStatus(project.ID)
+		if err := checkResponse(res, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "getting project %q export status", project.PathWithNamespace)
+		}
+		log.Debugf("project '%s' export %s", project.PathWithNamespace, status.ExportStatus)
+		if status.ExportStatus == "finished" {
+			break
+		}
+		if status.ExportStatus == "failed" {
+			return nil, errors.Errorf("project %q export failed: %s", project.PathWithNamespace, status.Message)
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("project %q export not finished after %v (%s)", project.PathWithNamespace, timeout, status.ExportStatus)
+		}
+		time.Sleep(exportPollInterval)
+	}
+	data, res, err := h.Client.ProjectImportExport.ExportDownload(project.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "downloading project %q export", project.PathWithNamespace)
+	}
+	return data, nil
+}
+
+// RepositoryArchive returns the tar.gz archive of the project default branch
+func (h *GitlabApi) RepositoryArchive(project *gitlab.Project) ([]byte, error) {
+	format := "tar.gz"
+	data, res, err := h.Client.Repositories.Archive(project.ID, &gitlab.ArchiveOptions{Format: &format})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "downloading project %q repository archive", project.PathWithNamespace)
+	}
+	return data, nil
+}
+
+// ImportProject uploads the exported file to create the project with the path in
+// the namespace, and returns the project id to wait for the import
+//
+// GitLab API docs: https://docs.gitlab.com/ee/api/project_import_export.html#import-a-file
+func (h *GitlabApi) ImportProject(file, namespace, path string, overwrite bool) (int, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, errors.Wrapf(err, "opening export file %q", file)
+	}
+	// the form parts around the file are kept in memory, the file is read again
+	// on every retry of the upload
+	head := &bytes.Buffer{}
+	w := multipart.NewWriter(head)
+	fields := [][2]string{{"path", path}, {"namespace", namespace}}
+	if overwrite {
+		fields = append(fields, [2]string{"overwrite", "true"})
+	}
+	for _, field := range fields {
+		if field[1] == "" {
+			continue
+		}
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return 0, errors.Wrap(err, "writing import form")
+		}
+	}
+	if _, err := w.CreateFormFile("file", filepath.Base(file)); err != nil {
+		return 0, errors.Wrap(err, "writing import form")
+	}
+	tail := &bytes.Buffer{}
+	w2 := multipart.NewWriter(tail)
+	w2.SetBoundary(w.Boundary())
+	w2.Close()
+	size := head.Len() + int(info.Size()) + tail.Len()
+	body := func() (io.Reader, error) {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "opening export file %q", file)
+		}
+		return &uploadReader{
+			Reader: io.MultiReader(bytes.NewReader(head.Bytes()), f, bytes.NewReader(tail.Bytes())),
+			Closer: f,
+			size:   size,
+		}, nil
+	}
+
+	req, err := retryablehttp.NewRequest("POST", h.Client.BaseURL().String()+"projects/import", retryablehttp.ReaderFunc(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if h.Client.UserAgent != "" {
+		req.Header.Set("User-Agent", h.Client.UserAgent)
+	}
+
+	status := &gitlab.ImportStatus{}
+	res, err := h.Client.Do(req, status)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return 0, errors.Wrapf(err, "importing project %s/%s", namespace, path)
+	}
+	return status.ID, nil
+}
+
+// uploadReader is a file upload body with its size, for the Content-Length
+type uploadReader struct {
+	io.Reader
+	io.Closer
+	size int
+}
+
+func (r *uploadReader) Len() int {
+	return r.size
+}
+
+// importStatus is the project import status with the import error, missing in gitlab.ImportStatus
+type importStatus struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	ImportStatus      string `json:"import_status"`
+	ImportError       string `json:"import_error"`
+}
+
+// ImportFailedError is the error of a project import that ended failed, which can be
+// uploaded again
+type ImportFailedError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ImportFailedError) Error() string {
+	return fmt.Sprintf("project %q import failed: %s", e.Path, e.Reason)
+}
+
+// WaitImport waits for the project import up to the timeout and returns the
+// imported project, or an ImportFailedError when the import failed
+func (h *GitlabApi) WaitImport(id int, timeout time.Duration) (*gitlab.Project, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		req, err := h.Client.NewRequest("GET", fmt.Sprintf("projects/%d/import", id), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		status := &importStatus{}
+		res, err := h.Client.Do(req, status)
+		if err := checkResponse(res, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "getting project %d import status", id)
+		}
+		log.Debugf("project '%s' import %s", status.PathWithNamespace, status.ImportStatus)
+		if status.ImportStatus == "finished" {
+			break
+		}
+		if status.ImportStatus == "failed" {
+			return nil, &ImportFailedError{Path: status.PathWithNamespace, Reason: status.ImportError}
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Errorf("project %q import not finished after %v (%s)", status.PathWithNamespace, timeout, status.ImportStatus)
+		}
+		time.Sleep(exportPollInterval)
+	}
+	return h.GetProject(id)
+}
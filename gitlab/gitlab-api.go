@@ -3,8 +3,13 @@ package utils
 import (
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/apex/log"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/janusky/gitlab-api-client/utils"
 
 	"github.com/pkg/errors"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -27,19 +32,72 @@ func checkResponse(res *gitlab.Response, err error, acceptable func(int) bool) e
 // GitlabApi is our wrapper tool around gitlab.Client
 type GitlabApi struct {
 	Client *gitlab.Client
+
+	usersMu sync.Mutex
+	users   map[string]*gitlab.User
 }
 
+// Token types of NewGitlabApiToken
+const (
+	PrivateToken = "private"
+	OAuthToken   = "oauth"
+	JobToken     = "job"
+)
+
 // NewGitlabApi creates a new gitlab api and returns it
 func NewGitlabApi(httpClient *http.Client, apiBaseURL string, privateToken string) *GitlabApi {
-	optURL := gitlab.WithBaseURL(apiBaseURL)
-	optHTTP := gitlab.WithHTTPClient(httpClient)
-	client, err := gitlab.NewClient(privateToken, optURL, optHTTP)
+	api, err := NewGitlabApiToken(httpClient, apiBaseURL, privateToken, PrivateToken)
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
+	return api
+}
+
+// NewGitlabApiToken creates a new gitlab api authenticated with the token of the type
+// (private, oauth or job) and returns it
+func NewGitlabApiToken(httpClient *http.Client, apiBaseURL, token, tokenType string) (*GitlabApi, error) {
+	optURL := gitlab.WithBaseURL(apiBaseURL)
+	var client *gitlab.Client
+	var err error
+	switch tokenType {
+	case PrivateToken, "":
+		client, err = gitlab.NewClient(token, optURL, gitlab.WithHTTPClient(httpClient))
+	case OAuthToken:
+		client, err = gitlab.NewOAuthClient(token, optURL, gitlab.WithHTTPClient(httpClient))
+	case JobToken:
+		jobClient := *httpClient
+		jobClient.Transport = &jobTokenTransport{token: token, next: httpClient.Transport}
+		client, err = gitlab.NewClient("", optURL, gitlab.WithHTTPClient(&jobClient))
+	default:
+		return nil, errors.Errorf("unknown token type: %s (private, oauth or job)", tokenType)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "creating gitlab client")
+	}
 	return &GitlabApi{
 		Client: client,
-	}
+	}, nil
+}
+
+// jobTokenTransport authenticates the requests with a CI job token, not supported by gitlab.Client
+type jobTokenTransport struct {
+	token string
+	next  http.RoundTripper
+}
+
+func (t *jobTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := *req
+	clone.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		clone.Header[k] = v
+	}
+	clone.Header.Del("PRIVATE-TOKEN")
+	clone.Header.Set("JOB-TOKEN", t.token)
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(&clone)
 }
 
 func (h *GitlabApi) EnumGroupProjects(group *gitlab.Group) (<-chan *gitlab.Project, <-chan error) {
@@ -225,6 +283,43 @@ func (h *GitlabApi) ListProjectBranches(project *gitlab.Project) ([]*gitlab.Bran
 	return all, nil
 }
 
+func (h *GitlabApi) DeleteBranch(project *gitlab.Project, branch string) error {
+	res, err := h.Client.Branches.DeleteBranch(project.ID, branch)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "deleting branch %q in project %q", branch, project.PathWithNamespace)
+	}
+	return nil
+}
+
+func (h *GitlabApi) ProtectBranch(project *gitlab.Project, branch string, push, merge gitlab.AccessLevelValue) error {
+	_, res, err := h.Client.ProtectedBranches.ProtectRepositoryBranches(project.ID, &gitlab.ProtectRepositoryBranchesOptions{
+		Name:             &branch,
+		PushAccessLevel:  &push,
+		MergeAccessLevel: &merge,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "protecting branch %q in project %q", branch, project.PathWithNamespace)
+	}
+	return nil
+}
+
+func (h *GitlabApi) ListProtectedBranches(project *gitlab.Project) ([]*gitlab.ProtectedBranch, error) {
+	opts := &gitlab.ListProtectedBranchesOptions{Page: 1}
+	all := make([]*gitlab.ProtectedBranch, 0)
+	for {
+		branches, resp, err := h.Client.ProtectedBranches.ListProtectedBranches(project.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrap(err, "listing project protected branches")
+		}
+		all = append(all, branches...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
 func (h *GitlabApi) ListProjectTags(project *gitlab.Project) ([]*gitlab.Tag, error) {
 	opts := &gitlab.ListTagsOptions{
 		ListOptions: gitlab.ListOptions{
@@ -281,59 +376,48 @@ func (h *GitlabApi) ListTree(project *gitlab.Project, ref string, path string) (
 	return all, nil
 }
 
-func (h *GitlabApi) GetUser(username string) (*gitlab.User, error) {
-	users, res, err := h.Client.Users.ListUsers(&gitlab.ListUsersOptions{
-		Search: &username,
-	})
-	if err := checkResponse(res, err, is2xx); err != nil {
-		return nil, errors.Wrap(err, "listing users")
-	}
-	if len(users) > 1 {
-		return nil, errors.Errorf("multiple users found for username '%s'", username)
-	}
-	if len(users) == 0 {
-		return nil, errors.Errorf("no users found for username '%s'", username)
-	}
-	return users[0], nil
-}
-
+// ReplaceOwners makes the users the owners of the project, adding the missing ones
+// and promoting the members, and removes the other direct owners
 func (h *GitlabApi) ReplaceOwners(project *gitlab.Project, owners ...*gitlab.User) error {
-	err := h.AddMembers(project, gitlab.AccessLevel(gitlab.OwnerPermission), owners...)
+	members, err := h.ListProjectMembers(project, false)
 	if err != nil {
-		return errors.Wrap(err, "adding project owners")
-	}
-	opts := &gitlab.ListProjectMembersOptions{
-		ListOptions: gitlab.ListOptions{
-			Page: 1,
-		},
+		return err
 	}
-	for {
-		members, resp, err := h.Client.ProjectMembers.ListProjectMembers(project.ID, opts)
-		if err := checkResponse(resp, err, is2xx); err != nil {
-			return errors.Wrap(err, "listing project members")
-		}
+	isOwner := make(map[int]bool, len(owners))
+	for _, owner := range owners {
+		isOwner[owner.ID] = true
+		var current *gitlab.ProjectMember
 		for _, member := range members {
-			if member.AccessLevel != gitlab.OwnerPermission {
-				continue
+			if member.ID == owner.ID {
+				current = member
+				break
 			}
-			remove := true
-			for _, owner := range owners {
-				if member.ID == owner.ID {
-					remove = false
-					break
-				}
+		}
+		switch {
+		case current == nil:
+			if err := h.AddMembers(project, gitlab.AccessLevel(gitlab.OwnerPermission), owner); err != nil {
+				return errors.Wrap(err, "adding project owners")
 			}
-			if remove {
-				resp, err := h.Client.ProjectMembers.DeleteProjectMember(project.ID, member.ID)
-				if err := checkResponse(resp, err, is2xx); err != nil {
-					return errors.Wrap(err, "deleting project member")
-				}
+		case current.AccessLevel != gitlab.OwnerPermission:
+			var expiresAt *string
+			if current.ExpiresAt != nil {
+				expiresAt = gitlab.String(current.ExpiresAt.String())
+			}
+			if err := h.EditProjectMember(project, current.ID, &gitlab.EditProjectMemberOptions{
+				AccessLevel: gitlab.AccessLevel(gitlab.OwnerPermission),
+				ExpiresAt:   expiresAt,
+			}); err != nil {
+				return errors.Wrap(err, "adding project owners")
 			}
 		}
-		if resp.CurrentPage >= resp.TotalPages {
-			break
+	}
+	for _, member := range members {
+		if member.AccessLevel != gitlab.OwnerPermission || isOwner[member.ID] {
+			continue
+		}
+		if err := h.RemoveProjectMember(project, member.ID); err != nil {
+			return errors.Wrap(err, "deleting project member")
 		}
-		opts.Page = resp.NextPage
 	}
 	return nil
 }
@@ -351,112 +435,304 @@ func (h *GitlabApi) AddMembers(project *gitlab.Project, perm *gitlab.AccessLevel
 	return nil
 }
 
-func (h *GitlabApi) CreateProjects(gitlabOwners []string, gitlabGroup string, gitlabProjects []string) error {
-	owners := make([]*gitlab.User, 0)
-	for _, user := range gitlabOwners {
-		owner, err := h.GetUser(user)
-		if err != nil {
-			return errors.Wrap(err, "getting user")
-		}
-		owners = append(owners, owner)
+// ProjectSpec is a project to create and its settings, the empty ones keep the Gitlab defaults
+type ProjectSpec struct {
+	Name                 string
+	Visibility           gitlab.VisibilityValue
+	Description          string
+	DefaultBranch        string
+	InitializeWithReadme bool
+	Topics               []string
+	TemplateName         string
+	CIConfigPath         string
+	MergeMethod          gitlab.MergeMethodValue
+	OwnerAccess          gitlab.AccessLevelValue
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
 	}
-	gs, err := h.ListGroups(&gitlab.ListGroupsOptions{
-		Search: &gitlabGroup,
-	})
-	if err != nil {
-		return errors.Wrap(err, "listing groups")
-	}
-	groupFound := false
-	var g *gitlab.Group
-	for _, g = range gs {
-		log.Infof("checking group %s", g.Name)
-		if g.Name == gitlabGroup {
-			groupFound = true
-			log.Infof("using group %q (%d)", g.Name, g.ID)
-			break
+	return &s
+}
+
+func (s *ProjectSpec) createOptions(namespaceID *int) *gitlab.CreateProjectOptions {
+	opts := &gitlab.CreateProjectOptions{
+		Name:          &s.Name,
+		NamespaceID:   namespaceID,
+		Description:   optionalString(s.Description),
+		DefaultBranch: optionalString(s.DefaultBranch),
+		TemplateName:  optionalString(s.TemplateName),
+		CIConfigPath:  optionalString(s.CIConfigPath),
+	}
+	if s.Visibility != "" {
+		opts.Visibility = gitlab.Visibility(s.Visibility)
+	}
+	if s.InitializeWithReadme {
+		opts.InitializeWithReadme = &s.InitializeWithReadme
+	}
+	if len(s.Topics) > 0 {
+		opts.TagList = &s.Topics
+	}
+	if s.MergeMethod != "" {
+		opts.MergeMethod = gitlab.MergeMethod(s.MergeMethod)
+	}
+	return opts
+}
+
+// EnsureGroup returns the group with the full path, creating every missing group of
+// the path under its parent
+func (h *GitlabApi) EnsureGroup(fullPath string, visibility gitlab.VisibilityValue) (*gitlab.Group, error) {
+	var parent *gitlab.Group
+	// the groups under a created group are missing too
+	created := false
+	segments := strings.Split(strings.Trim(fullPath, "/"), "/")
+	for i, segment := range segments {
+		path := strings.Join(segments[:i+1], "/")
+		var g *gitlab.Group
+		if !created {
+			var err error
+			g, err = h.FindGroup(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if g != nil {
+			log.Infof("using group %q (%d)", g.FullPath, g.ID)
+			parent = g
+			continue
+		}
+		log.Infof("creating group %q", path)
+		opts := &gitlab.CreateGroupOptions{
+			Path:       &segment,
+			Name:       &segment,
+			Visibility: gitlab.Visibility(visibility),
 		}
+		if parent != nil {
+			opts.ParentID = &parent.ID
+		}
+		g, r, err := h.Client.Groups.CreateGroup(opts)
+		if err := checkResponse(r, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "creating group %q", path)
+		}
+		log.Infof("created group %q (%d)", path, g.ID)
+		parent = g
+		created = true
 	}
-	if !groupFound {
-		log.Infof("creating group %q", gitlabGroup)
-		var r *gitlab.Response
-		g, r, err = h.Client.Groups.CreateGroup(&gitlab.CreateGroupOptions{
-			Path:       &gitlabGroup,
-			Name:       &gitlabGroup,
-			Visibility: gitlab.Visibility(gitlab.PublicVisibility),
-		})
+	return parent, nil
+}
+
+func (h *GitlabApi) getUsers(usernames []string) ([]*gitlab.User, error) {
+	users := make([]*gitlab.User, 0)
+	for _, username := range usernames {
+		user, err := h.GetUser(username)
 		if err != nil {
-			return errors.Wrapf(err, "creating group %q", gitlabGroup)
+			return nil, errors.Wrap(err, "getting user")
 		}
-		if r.StatusCode/100 != 2 {
-			return errors.Errorf("unexpected gitlab response %v creating group %q", r.StatusCode, gitlabGroup)
-		}
-		log.Infof("created group %q (%d)", gitlabGroup, g.ID)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (h *GitlabApi) CreateProjects(gitlabOwners []string, gitlabGroup string, groupVisibility gitlab.VisibilityValue, gitlabProjects []*ProjectSpec) error {
+	owners, err := h.getUsers(gitlabOwners)
+	if err != nil {
+		return err
+	}
+	g, err := h.EnsureGroup(gitlabGroup, groupVisibility)
+	if err != nil {
+		return err
 	}
 	projects, err := h.ListGroupProjects(g)
 	if err != nil {
 		return errors.Wrap(err, "listing group projects")
 	}
-	for _, name := range gitlabProjects {
+	return h.createProjects(owners, &g.ID, "group "+g.FullPath, projects, gitlabProjects)
+}
+
+// CreateUserProjects creates the projects in the personal namespace of the user, the
+// current one when empty, or as the user with sudo when it is another one (admin only)
+func (h *GitlabApi) CreateUserProjects(gitlabOwners []string, username string, gitlabProjects []*ProjectSpec) error {
+	owners, err := h.getUsers(gitlabOwners)
+	if err != nil {
+		return err
+	}
+	user, sudo, err := h.namespaceUser(username)
+	if err != nil {
+		return err
+	}
+	projects, err := h.ListUserProjects(user)
+	if err != nil {
+		return err
+	}
+	return h.createProjects(owners, nil, "user "+user.Username, projects, gitlabProjects, sudo...)
+}
+
+// namespaceUser returns the user, the current one when empty, and the request options to act as it
+func (h *GitlabApi) namespaceUser(username string) (*gitlab.User, []gitlab.RequestOptionFunc, error) {
+	current, err := h.CurrentUser()
+	if err != nil {
+		return nil, nil, err
+	}
+	if username == "" || strings.EqualFold(username, current.Username) {
+		return current, nil, nil
+	}
+	if !current.IsAdmin {
+		return nil, nil, errors.Errorf("user %q namespace is only available for admins", username)
+	}
+	user, err := h.GetUser(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, []gitlab.RequestOptionFunc{gitlab.WithSudo(user.ID)}, nil
+}
+
+// createProjects creates the missing projects in the namespace, the user one when namespaceID is nil
+func (h *GitlabApi) createProjects(owners []*gitlab.User, namespaceID *int, namespace string, projects []*gitlab.Project, gitlabProjects []*ProjectSpec, options ...gitlab.RequestOptionFunc) error {
+	for _, spec := range gitlabProjects {
+		name := spec.Name
 		found := false
 		for _, project := range projects {
 			if project.Name == name {
 				found = true
-				log.Infof("found existing project %q in group %q (%d)", name, g.Name, g.ID)
+				log.Infof("found existing project %q in %s", name, namespace)
 				break
 			}
 		}
 		if !(found) {
-			p, r, err := h.Client.Projects.CreateProject(&gitlab.CreateProjectOptions{
-				Name:        &name,
-				NamespaceID: &g.ID,
-				Visibility:  gitlab.Visibility(gitlab.PublicVisibility),
-			})
+			p, r, err := h.Client.Projects.CreateProject(spec.createOptions(namespaceID), options...)
 			if err != nil {
-				return errors.Wrapf(err, "creating project %q in group %q", name, gitlabGroup)
+				return errors.Wrapf(err, "creating project %q in %s", name, namespace)
 			}
 			if r.StatusCode/100 != 2 {
-				return errors.Errorf("unexpected gitlab response %v creating project %q in group %q", r.StatusCode, name, gitlabGroup)
+				return errors.Errorf("unexpected gitlab response %v creating project %q in %s", r.StatusCode, name, namespace)
 			}
-			log.Infof("created project %q (%d) in group %q (%d)", name, p.ID, gitlabGroup, g.ID)
-			level := gitlab.MasterPermissions
+			log.Infof("created project %q (%d) in %s", name, p.ID, namespace)
+			level := spec.OwnerAccess
 			for _, owner := range owners {
+				if p.Owner != nil && p.Owner.ID == owner.ID {
+					continue
+				}
 				_, r, err := h.Client.ProjectMembers.AddProjectMember(p.ID, &gitlab.AddProjectMemberOptions{
 					AccessLevel: &level,
 					UserID:      &(owner.ID),
 				})
 				if err != nil {
-					return errors.Wrapf(err, "adding project owner %q on project %q", name, gitlabGroup)
+					return errors.Wrapf(err, "adding project owner %q on project %q", owner.Username, name)
 				}
 				if r.StatusCode/100 != 2 {
-					return errors.Errorf("unexpected gitlab response %v creating project %q in group %q", r.StatusCode, name, gitlabGroup)
+					return errors.Errorf("unexpected gitlab response %v adding project owner %q on project %q", r.StatusCode, owner.Username, name)
 				}
-				log.Infof("added user %q (%d) as owner of project %q (%d) in group %q (%d)", owner.Username, owner.ID, p.Name, p.ID, g.Name, g.ID)
+				log.Infof("added user %q (%d) as owner of project %q (%d) in %s", owner.Username, owner.ID, p.Name, p.ID, namespace)
 			}
 		}
 	}
 	return nil
 }
 
-func (h *GitlabApi) EnableDeployKey(deployKey *gitlab.DeployKey, idProject int) error {
-	_, res, err := h.Client.DeployKeys.AddDeployKey(idProject, &gitlab.AddDeployKeyOptions{
-		Key:     &deployKey.Key,
-		Title:   &deployKey.Title,
-		CanPush: deployKey.CanPush,
-	})
+// ListUserNamespaceProjects returns the projects of the user personal namespace, the
+// current user one when empty
+func (h *GitlabApi) ListUserNamespaceProjects(username string) ([]*gitlab.Project, error) {
+	user, _, err := h.namespaceUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return h.ListUserProjects(user)
+}
+
+func (h *GitlabApi) AddDeployKey(project *gitlab.Project, opts *gitlab.AddDeployKeyOptions) (*gitlab.DeployKey, error) {
+	deployKey, res, err := h.Client.DeployKeys.AddDeployKey(project.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "adding deploy key %q in project %q", *opts.Title, project.PathWithNamespace)
+	}
+	return deployKey, nil
+}
+
+// EnableProjectDeployKey enables an existing deploy key, by its id, in the project
+func (h *GitlabApi) EnableProjectDeployKey(project *gitlab.Project, idDeployKey int) error {
+	_, res, err := h.Client.DeployKeys.EnableDeployKey(project.ID, idDeployKey)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "enabling deploy key %d in project %q", idDeployKey, project.PathWithNamespace)
+	}
+	return nil
+}
+
+// DisableProjectDeployKey removes the deploy key from the project
+func (h *GitlabApi) DisableProjectDeployKey(project *gitlab.Project, deployKey *gitlab.DeployKey) error {
+	res, err := h.Client.DeployKeys.DeleteDeployKey(project.ID, deployKey.ID)
 	if err := checkResponse(res, err, is2xx); err != nil {
-		return errors.Wrapf(err, "adding deploy key (%d %s) in project %d", deployKey.ID, deployKey.Title, idProject)
+		return errors.Wrapf(err, "deleting deploy key (%d %s) in project %q", deployKey.ID, deployKey.Title, project.PathWithNamespace)
 	}
 	return nil
 }
 
-func (h *GitlabApi) DisabledDeployKey(deployKey *gitlab.DeployKey, idProject int) error {
-	res, err := h.Client.DeployKeys.DeleteDeployKey(idProject, deployKey.ID)
+func (h *GitlabApi) UpdateProjectDeployKey(project *gitlab.Project, deployKey *gitlab.DeployKey, opts *gitlab.UpdateDeployKeyOptions) error {
+	_, res, err := h.Client.DeployKeys.UpdateDeployKey(project.ID, deployKey.ID, opts)
 	if err := checkResponse(res, err, is2xx); err != nil {
-		return errors.Wrapf(err, "deleting deploy key (%d %s) in project %d", deployKey.ID, deployKey.Title, idProject)
+		return errors.Wrapf(err, "updating deploy key (%d %s) in project %q", deployKey.ID, deployKey.Title, project.PathWithNamespace)
 	}
 	return nil
 }
 
+// withPage sets the page of the list requests without list options
+func withPage(page int) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		query := req.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", "100")
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
+
+// ListAllDeployKeys lists the deploy keys of the instance (admin only)
+func (h *GitlabApi) ListAllDeployKeys() ([]*gitlab.DeployKey, error) {
+	page := 1
+	all := make([]*gitlab.DeployKey, 0)
+	for {
+		keys, resp, err := h.Client.DeployKeys.ListAllDeployKeys(withPage(page))
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrap(err, "listing all deploy keys")
+		}
+		all = append(all, keys...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		page = resp.NextPage
+	}
+	return all, nil
+}
+
+// FindDeployKey returns the key with the id, or with the fingerprint when id is 0
+func FindDeployKey(keys []*gitlab.DeployKey, id int, fingerprint string) *gitlab.DeployKey {
+	for _, key := range keys {
+		if id != 0 {
+			if key.ID == id {
+				return key
+			}
+			continue
+		}
+		pub, err := utils.ParseSSHPublicKey(key.Key)
+		if err != nil {
+			log.Debugf("skipped deploy key %d: %v", key.ID, err)
+			continue
+		}
+		if pub.MatchFingerprint(fingerprint) {
+			return key
+		}
+	}
+	return nil
+}
+
+// FindProjectDeployKey returns the project deploy key with the id or the fingerprint, or nil
+func (h *GitlabApi) FindProjectDeployKey(project *gitlab.Project, id int, fingerprint string) (*gitlab.DeployKey, error) {
+	keys, err := h.ListDeployKeys(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	return FindDeployKey(keys, id, fingerprint), nil
+}
+
 func (h *GitlabApi) GetDeployKey(idProject, idDeployKey int) (*gitlab.DeployKey, error) {
 	deployKeyFind, resp, err := h.Client.DeployKeys.GetDeployKey(idProject, idDeployKey)
 	if err := checkResponse(resp, err, is2xx); err != nil {
@@ -483,3 +759,39 @@ func (h *GitlabApi) ListDeployKeys(idProject int) ([]*gitlab.DeployKey, error) {
 	}
 	return all, nil
 }
+
+func is404(res *gitlab.Response) bool {
+	return res != nil && res.StatusCode == 404
+}
+
+func (h *GitlabApi) GetProject(pid interface{}) (*gitlab.Project, error) {
+	project, res, err := h.Client.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting project %v", pid)
+	}
+	return project, nil
+}
+
+// FindProject returns the project, by id or path, or nil when it does not exist
+func (h *GitlabApi) FindProject(pid interface{}) (*gitlab.Project, error) {
+	project, res, err := h.Client.Projects.GetProject(pid, &gitlab.GetProjectOptions{})
+	if is404(res) {
+		return nil, nil
+	}
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting project %v", pid)
+	}
+	return project, nil
+}
+
+// FindGroup returns the group, by id or path, or nil when it does not exist
+func (h *GitlabApi) FindGroup(gid interface{}) (*gitlab.Group, error) {
+	group, res, err := h.Client.Groups.GetGroup(gid)
+	if is404(res) {
+		return nil, nil
+	}
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting group %v", gid)
+	}
+	return group, nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func (h *GitlabApi) ListProjectHooks(project *gitlab.Project) ([]*gitlab.ProjectHook, error) {
+	opts := &gitlab.ListProjectHooksOptions{Page: 1}
+	all := make([]*gitlab.ProjectHook, 0)
+	for {
+		hooks, resp, err := h.Client.Projects.ListProjectHooks(project.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing project %q hooks", project.PathWithNamespace)
+		}
+		all = append(all, hooks...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+// FindProjectHooks returns the project hooks with the url
+func (h *GitlabApi) FindProjectHooks(project *gitlab.Project, url string) ([]*gitlab.ProjectHook, error) {
+	hooks, err := h.ListProjectHooks(project)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*gitlab.ProjectHook, 0)
+	for _, hook := range hooks {
+		if hook.URL == url {
+			found = append(found, hook)
+		}
+	}
+	return found, nil
+}
+
+func (h *GitlabApi) AddProjectHook(project *gitlab.Project, opts *gitlab.AddProjectHookOptions) (*gitlab.ProjectHook, error) {
+	hook, res, err := h.Client.Projects.AddProjectHook(project.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "adding hook in project %q", project.PathWithNamespace)
+	}
+	return hook, nil
+}
+
+func (h *GitlabApi) EditProjectHook(project *gitlab.Project, hook *gitlab.ProjectHook, opts *gitlab.EditProjectHookOptions) (*gitlab.ProjectHook, error) {
+	edited, res, err := h.Client.Projects.EditProjectHook(project.ID, hook.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "editing hook %d in project %q", hook.ID, project.PathWithNamespace)
+	}
+	return edited, nil
+}
+
+func (h *GitlabApi) DeleteProjectHook(project *gitlab.Project, hook *gitlab.ProjectHook) error {
+	res, err := h.Client.Projects.DeleteProjectHook(project.ID, hook.ID)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "deleting hook %d in project %q", hook.ID, project.PathWithNamespace)
+	}
+	return nil
+}
+
+// SyncProjectHook adds the hook to the project, or updates the existing ones with the same url.
+// It returns the status of the hook: added or updated.
+func (h *GitlabApi) SyncProjectHook(project *gitlab.Project, opts *gitlab.AddProjectHookOptions) (string, error) {
+	hooks, err := h.FindProjectHooks(project, *opts.URL)
+	if err != nil {
+		return "", err
+	}
+	if len(hooks) == 0 {
+		_, err := h.AddProjectHook(project, opts)
+		if err != nil {
+			return "", err
+		}
+		return "added", nil
+	}
+	edit := gitlab.EditProjectHookOptions(*opts)
+	for _, hook := range hooks {
+		_, err := h.EditProjectHook(project, hook, &edit)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "updated", nil
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// ListProjectMembers returns the project members, with the inherited ones when all is true
+func (h *GitlabApi) ListProjectMembers(project *gitlab.Project, all bool) ([]*gitlab.ProjectMember, error) {
+	list := h.Client.ProjectMembers.ListProjectMembers
+	if all {
+		list = h.Client.ProjectMembers.ListAllProjectMembers
+	}
+	opts := &gitlab.ListProjectMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+	}
+	members := make([]*gitlab.ProjectMember, 0)
+	for {
+		page, resp, err := list(project.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing project %q members", project.PathWithNamespace)
+		}
+		members = append(members, page...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return members, nil
+}
+
+// ListUserMemberships returns the direct memberships of the user in groups and projects (admin only)
+func (h *GitlabApi) ListUserMemberships(user *gitlab.User) ([]*gitlab.UserMembership, error) {
+	opts := &gitlab.GetUserMembershipOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+	}
+	memberships := make([]*gitlab.UserMembership, 0)
+	for {
+		page, resp, err := h.Client.Users.GetUserMemberships(user.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing user %q memberships", user.Username)
+		}
+		memberships = append(memberships, page...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return memberships, nil
+}
+
+// ListGroupMembers returns the group members, with the inherited ones when all is true
+func (h *GitlabApi) ListGroupMembers(group *gitlab.Group, all bool) ([]*gitlab.GroupMember, error) {
+	list := h.Client.Groups.ListGroupMembers
+	if all {
+		list = h.Client.Groups.ListAllGroupMembers
+	}
+	opts := &gitlab.ListGroupMembersOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+	}
+	members := make([]*gitlab.GroupMember, 0)
+	for {
+		page, resp, err := list(group.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing group %q members", group.FullPath)
+		}
+		members = append(members, page...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return members, nil
+}
+
+func (h *GitlabApi) EditProjectMember(project *gitlab.Project, user int, opts *gitlab.EditProjectMemberOptions) error {
+	_, res, err := h.Client.ProjectMembers.EditProjectMember(project.ID, user, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "editing project %q member %d", project.PathWithNamespace, user)
+	}
+	return nil
+}
+
+func (h *GitlabApi) EditGroupMember(group *gitlab.Group, user int, opts *gitlab.EditGroupMemberOptions) error {
+	_, res, err := h.Client.GroupMembers.EditGroupMember(group.ID, user, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "editing group %q member %d", group.FullPath, user)
+	}
+	return nil
+}
+
+func (h *GitlabApi) RemoveProjectMember(project *gitlab.Project, user int) error {
+	res, err := h.Client.ProjectMembers.DeleteProjectMember(project.ID, user)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "removing project %q member %d", project.PathWithNamespace, user)
+	}
+	return nil
+}
+
+func (h *GitlabApi) RemoveGroupMember(group *gitlab.Group, user int) error {
+	res, err := h.Client.GroupMembers.RemoveGroupMember(group.ID, user)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "removing group %q member %d", group.FullPath, user)
+	}
+	return nil
+}
+
+func (h *GitlabApi) AddProjectMember(project *gitlab.Project, user *gitlab.User, level gitlab.AccessLevelValue, expiresAt *string) error {
+	_, res, err := h.Client.ProjectMembers.AddProjectMember(project.ID, &gitlab.AddProjectMemberOptions{
+		UserID:      &user.ID,
+		AccessLevel: &level,
+		ExpiresAt:   expiresAt,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "adding project %q member %q", project.PathWithNamespace, user.Username)
+	}
+	return nil
+}
+
+func (h *GitlabApi) AddGroupMember(group *gitlab.Group, user *gitlab.User, level gitlab.AccessLevelValue, expiresAt *string) error {
+	_, res, err := h.Client.GroupMembers.AddGroupMember(group.ID, &gitlab.AddGroupMemberOptions{
+		UserID:      &user.ID,
+		AccessLevel: &level,
+		ExpiresAt:   expiresAt,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return errors.Wrapf(err, "adding group %q member %q", group.FullPath, user.Username)
+	}
+	return nil
+}
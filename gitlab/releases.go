@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// ReleaseSpec describes the tag and the release to create in a project
+type ReleaseSpec struct {
+	Tag        string
+	Ref        string
+	Name       string
+	Message    string
+	Notes      string
+	Milestones []string
+	Links      []*gitlab.ReleaseAssetLink
+}
+
+// GetTag returns the project tag, or nil when it does not exist
+func (h *GitlabApi) GetTag(project *gitlab.Project, name string) (*gitlab.Tag, error) {
+	tag, res, err := h.Client.Tags.GetTag(project.ID, name)
+	if is404(res) {
+		return nil, nil
+	}
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting tag %q in project %q", name, project.PathWithNamespace)
+	}
+	return tag, nil
+}
+
+// GetRelease returns the project release of the tag, or nil when it does not exist
+func (h *GitlabApi) GetRelease(project *gitlab.Project, tagName string) (*gitlab.Release, error) {
+	release, res, err := h.Client.Releases.GetRelease(project.ID, tagName)
+	if is404(res) {
+		return nil, nil
+	}
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "getting release %q in project %q", tagName, project.PathWithNamespace)
+	}
+	return release, nil
+}
+
+// CreateRelease creates the annotated tag and the release described by spec.
+// Existing tags and releases are kept, so it can be run again safely.
+// It returns the status of the release: created or exists.
+func (h *GitlabApi) CreateRelease(project *gitlab.Project, spec *ReleaseSpec) (string, error) {
+	ref := spec.Ref
+	if ref == "" {
+		ref = project.DefaultBranch
+	}
+	tag, err := h.GetTag(project, spec.Tag)
+	if err != nil {
+		return "", err
+	}
+	if tag == nil {
+		if ref == "" {
+			return "", errors.Errorf("project %q has no default branch, use --ref", project.PathWithNamespace)
+		}
+		tag, res, err := h.Client.Tags.CreateTag(project.ID, &gitlab.CreateTagOptions{
+			TagName: &spec.Tag,
+			Ref:     &ref,
+			Message: &spec.Message,
+		})
+		if err := checkResponse(res, err, is2xx); err != nil {
+			return "", errors.Wrapf(err, "creating tag %q on %q in project %q", spec.Tag, ref, project.PathWithNamespace)
+		}
+		log.Infof("created tag %q on %q in project %q", tag.Name, ref, project.PathWithNamespace)
+	} else {
+		log.Infof("found existing tag %q in project %q", tag.Name, project.PathWithNamespace)
+	}
+	release, err := h.GetRelease(project, spec.Tag)
+	if err != nil {
+		return "", err
+	}
+	if release != nil {
+		log.Infof("found existing release %q in project %q", release.Name, project.PathWithNamespace)
+		return "exists", nil
+	}
+	name := spec.Name
+	if name == "" {
+		name = spec.Tag
+	}
+	opts := &gitlab.CreateReleaseOptions{
+		Name:        &name,
+		TagName:     &spec.Tag,
+		Description: &spec.Notes,
+		Milestones:  spec.Milestones,
+	}
+	if len(spec.Links) > 0 {
+		opts.Assets = &gitlab.ReleaseAssets{Links: spec.Links}
+	}
+	_, res, err := h.Client.Releases.CreateRelease(project.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return "", errors.Wrapf(err, "creating release %q in project %q", spec.Tag, project.PathWithNamespace)
+	}
+	log.Infof("created release %q in project %q", name, project.PathWithNamespace)
+	return "created", nil
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// RenameProject changes the project path and name, the empty ones are kept
+func (h *GitlabApi) RenameProject(project *gitlab.Project, path, name string) (*gitlab.Project, error) {
+	opts := &gitlab.EditProjectOptions{}
+	if path != "" {
+		opts.Path = &path
+	}
+	if name != "" {
+		opts.Name = &name
+	}
+	renamed, res, err := h.Client.Projects.EditProject(project.ID, opts)
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "renaming project %q to %q", project.PathWithNamespace, path)
+	}
+	return renamed, nil
+}
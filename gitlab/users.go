@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// GetUser resolves the user by exact username, by email or by numeric id, in this
+// order, caching the found users for the next lookups
+func (h *GitlabApi) GetUser(username string) (*gitlab.User, error) {
+	key := strings.ToLower(strings.TrimSpace(username))
+	h.usersMu.Lock()
+	user, ok := h.users[key]
+	h.usersMu.Unlock()
+	if ok {
+		return user, nil
+	}
+	user, err := h.resolveUser(strings.TrimSpace(username))
+	if err != nil {
+		return nil, err
+	}
+	h.usersMu.Lock()
+	if h.users == nil {
+		h.users = make(map[string]*gitlab.User)
+	}
+	h.users[key] = user
+	h.users[strings.ToLower(user.Username)] = user
+	h.usersMu.Unlock()
+	return user, nil
+}
+
+func (h *GitlabApi) resolveUser(username string) (*gitlab.User, error) {
+	if username == "" {
+		return nil, errors.New("empty username")
+	}
+	users, res, err := h.Client.Users.ListUsers(&gitlab.ListUsersOptions{
+		Username: &username,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrap(err, "listing users")
+	}
+	if len(users) == 1 {
+		return users[0], nil
+	}
+	if id, err := strconv.Atoi(username); err == nil {
+		user, res, err := h.Client.Users.GetUser(id)
+		if !is404(res) {
+			if err := checkResponse(res, err, is2xx); err != nil {
+				return nil, errors.Wrapf(err, "getting user %d", id)
+			}
+			return user, nil
+		}
+	}
+	users, err = h.searchUsers(username)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]*gitlab.User, 0)
+	for _, user := range users {
+		if strings.EqualFold(user.Username, username) || strings.EqualFold(user.Email, username) || strings.EqualFold(user.PublicEmail, username) {
+			matches = append(matches, user)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		users = matches
+	}
+	switch len(users) {
+	case 0:
+		return nil, errors.Errorf("no users found for username '%s'", username)
+	case 1:
+		if strings.Contains(username, "@") {
+			// the emails are not always returned, a single search result is the user
+			return users[0], nil
+		}
+	}
+	candidates := make([]string, 0, len(users))
+	for _, user := range users {
+		candidates = append(candidates, user.Username)
+	}
+	return nil, errors.Errorf("no exact match for username '%s', candidates: %s", username, strings.Join(candidates, ", "))
+}
+
+// searchUsers returns all the pages of the users matching the search
+func (h *GitlabApi) searchUsers(search string) ([]*gitlab.User, error) {
+	opts := &gitlab.ListUsersOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+		Search: &search,
+	}
+	all := make([]*gitlab.User, 0)
+	for {
+		users, resp, err := h.Client.Users.ListUsers(opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrap(err, "listing users")
+		}
+		all = append(all, users...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) CurrentUser() (*gitlab.User, error) {
+	user, res, err := h.Client.Users.CurrentUser()
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrap(err, "getting current user")
+	}
+	return user, nil
+}
+
+// ListUserProjects returns the projects in the personal namespace of the user
+func (h *GitlabApi) ListUserProjects(user *gitlab.User) ([]*gitlab.Project, error) {
+	opts := &gitlab.ListProjectsOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+	}
+	all := make([]*gitlab.Project, 0)
+	for {
+		projects, resp, err := h.Client.Projects.ListUserProjects(user.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing user %q projects", user.Username)
+		}
+		all = append(all, projects...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+// TransferProject moves the project to the namespace, given by id or path
+func (h *GitlabApi) TransferProject(project *gitlab.Project, namespace interface{}) (*gitlab.Project, error) {
+	transferred, res, err := h.Client.Projects.TransferProject(project.ID, &gitlab.TransferProjectOptions{
+		Namespace: namespace,
+	})
+	if err := checkResponse(res, err, is2xx); err != nil {
+		return nil, errors.Wrapf(err, "transferring project %q to %v", project.PathWithNamespace, namespace)
+	}
+	return transferred, nil
+}
+
+func (h *GitlabApi) ListUserSSHKeys(user *gitlab.User) ([]*gitlab.SSHKey, error) {
+	opts := &gitlab.ListSSHKeysForUserOptions{Page: 1}
+	all := make([]*gitlab.SSHKey, 0)
+	for {
+		keys, resp, err := h.Client.Users.ListSSHKeysForUser(user.ID, opts)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing user %q ssh keys", user.Username)
+		}
+		all = append(all, keys...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+type listPersonalAccessTokensOptions struct {
+	gitlab.ListOptions
+	UserID *int `url:"user_id,omitempty" json:"user_id,omitempty"`
+}
+
+// ListUserPersonalAccessTokens returns the user personal access tokens, only available for admins
+//
+// GitLab API docs: https://docs.gitlab.com/ee/api/personal_access_tokens.html
+func (h *GitlabApi) ListUserPersonalAccessTokens(user *gitlab.User) ([]*AccessToken, error) {
+	opts := &listPersonalAccessTokensOptions{
+		ListOptions: gitlab.ListOptions{
+			Page: 1,
+		},
+		UserID: &user.ID,
+	}
+	all := make([]*AccessToken, 0)
+	for {
+		req, err := h.Client.NewRequest("GET", "personal_access_tokens", opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		var tokens []*AccessToken
+		resp, err := h.Client.Do(req, &tokens)
+		if err := checkResponse(resp, err, is2xx); err != nil {
+			return nil, errors.Wrapf(err, "listing user %q personal access tokens", user.Username)
+		}
+		all = append(all, tokens...)
+		if resp.CurrentPage >= resp.TotalPages {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return all, nil
+}
+
+func (h *GitlabApi) BlockUser(user *gitlab.User) error {
+	if err := h.Client.Users.BlockUser(user.ID); err != nil {
+		return errors.Wrapf(err, "blocking user %q", user.Username)
+	}
+	return nil
+}
@@ -4,10 +4,12 @@ go 1.12
 
 require (
 	github.com/apex/log v1.8.0
+	github.com/hashicorp/go-retryablehttp v0.6.4
 	github.com/mattn/go-isatty v0.0.8
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/viper v1.7.1
 	github.com/xanzy/go-gitlab v0.42.0
+	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 )
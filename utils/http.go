@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPOptions are the TLS, proxy and timeout settings of the http client
+type HTTPOptions struct {
+	// TrustedCertificates are the PEM certificates trusted besides the system ones
+	TrustedCertificates [][]byte
+	// ClientCertificate and ClientKey are the PEM certificate and key of mTLS
+	ClientCertificate []byte
+	ClientKey         []byte
+	// MinTLSVersion is the minimum TLS version (1.0, 1.1, 1.2 or 1.3)
+	MinTLSVersion string
+	// Insecure skips the verification of the server certificate
+	Insecure bool
+	// Proxy is the proxy url, the environment proxy settings are used when empty
+	Proxy string
+	// NoProxy are the comma separated hosts, domains and networks without Proxy
+	NoProxy string
+	// Timeout is the time limit of the requests, including the response body
+	Timeout time.Duration
+	// ConnectTimeout is the time limit of the connection and TLS handshake
+	ConnectTimeout time.Duration
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// HTTPClient creates a new http client
+func HTTPClient(trustedCertificates [][]byte) (*http.Client, error) {
+	return NewHTTPClient(&HTTPOptions{TrustedCertificates: trustedCertificates})
+}
+
+// NewHTTPClient creates a new http client with the options
+func NewHTTPClient(opts *HTTPOptions) (*http.Client, error) {
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc(opts.Proxy, opts.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   opts.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: opts.ConnectTimeout,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}, nil
+}
+
+func tlsConfig(opts *HTTPOptions) (*tls.Config, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating system certificate pool")
+	}
+	for _, cert := range opts.TrustedCertificates {
+		if !pool.AppendCertsFromPEM(cert) {
+			return nil, errors.Errorf("no certificates was parsed from %q", cert)
+		}
+	}
+	config := &tls.Config{
+		RootCAs:            pool,
+		InsecureSkipVerify: opts.Insecure,
+	}
+	if opts.MinTLSVersion != "" {
+		version, ok := tlsVersions[opts.MinTLSVersion]
+		if !ok {
+			return nil, errors.Errorf("unknown TLS version: %s (1.0, 1.1, 1.2 or 1.3)", opts.MinTLSVersion)
+		}
+		config.MinVersion = version
+	}
+	if len(opts.ClientCertificate) > 0 || len(opts.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(opts.ClientCertificate, opts.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// proxyFunc returns the proxy of the requests, the environment one when proxy is
+// empty, without proxy for the hosts matching the noProxy rules
+func proxyFunc(proxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	rules := make([]string, 0)
+	for _, rule := range strings.Split(noProxy, ",") {
+		if rule = strings.ToLower(strings.TrimSpace(rule)); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	next := http.ProxyFromEnvironment
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, errors.Errorf("invalid proxy url %q", proxy)
+		}
+		next = http.ProxyURL(proxyURL)
+	}
+	if len(rules) == 0 {
+		return next, nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if matchNoProxy(req.URL, rules) {
+			return nil, nil
+		}
+		return next(req)
+	}, nil
+}
+
+// matchNoProxy reports if the url host matches a rule: * for every host, an ip, a
+// network in CIDR notation, or a domain with its subdomains, with an optional port
+func matchNoProxy(u *url.URL, rules []string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	ip := net.ParseIP(host)
+	for _, rule := range rules {
+		if rule == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(rule); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		ruleHost, rulePort, err := net.SplitHostPort(rule)
+		if err != nil {
+			ruleHost, rulePort = rule, ""
+		}
+		if rulePort != "" && rulePort != port {
+			continue
+		}
+		ruleHost = strings.TrimPrefix(strings.Trim(ruleHost, "[]"), "*")
+		if ruleIP := net.ParseIP(ruleHost); ruleIP != nil {
+			if ip != nil && ruleIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(ruleHost, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SSHPublicKey is a public key in the authorized_keys format (type base64 comment)
+type SSHPublicKey struct {
+	Type    string
+	Blob    []byte
+	Comment string
+}
+
+// ParseSSHPublicKey parses a public key in the authorized_keys format
+func ParseSSHPublicKey(text string) (*SSHPublicKey, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, errors.Errorf("invalid ssh public key %q", text)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding ssh public key")
+	}
+	return &SSHPublicKey{
+		Type:    fields[0],
+		Blob:    blob,
+		Comment: strings.Join(fields[2:], " "),
+	}, nil
+}
+
+// FingerprintMD5 returns the key fingerprint as aa:bb:...
+func (k *SSHPublicKey) FingerprintMD5() string {
+	sum := md5.Sum(k.Blob)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// FingerprintSHA256 returns the key fingerprint as SHA256:base64
+func (k *SSHPublicKey) FingerprintSHA256() string {
+	sum := sha256.Sum256(k.Blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// MatchFingerprint reports if the fingerprint, in MD5 or SHA256 form, is the key one
+func (k *SSHPublicKey) MatchFingerprint(fingerprint string) bool {
+	fingerprint = strings.TrimPrefix(fingerprint, "MD5:")
+	return strings.EqualFold(fingerprint, k.FingerprintMD5()) || fingerprint == k.FingerprintSHA256()
+}
+
+// readSSHString reads a length prefixed field of the key wire format
+func readSSHString(blob []byte) ([]byte, []byte, error) {
+	if len(blob) < 4 {
+		return nil, nil, errors.New("short ssh key data")
+	}
+	n := binary.BigEndian.Uint32(blob)
+	if uint32(len(blob)-4) < n {
+		return nil, nil, errors.New("short ssh key data")
+	}
+	return blob[4 : 4+n], blob[4+n:], nil
+}
+
+// Bits returns the key size in bits, from the RSA modulus, the DSA prime or the curve
+func (k *SSHPublicKey) Bits() (int, error) {
+	algorithm, rest, err := readSSHString(k.Blob)
+	if err != nil {
+		return 0, err
+	}
+	switch string(algorithm) {
+	case "ssh-rsa":
+		// e, n
+		_, rest, err = readSSHString(rest)
+		if err != nil {
+			return 0, err
+		}
+		n, _, err := readSSHString(rest)
+		if err != nil {
+			return 0, err
+		}
+		return new(big.Int).SetBytes(n).BitLen(), nil
+	case "ssh-dss":
+		// p, q, g, y
+		p, _, err := readSSHString(rest)
+		if err != nil {
+			return 0, err
+		}
+		return new(big.Int).SetBytes(p).BitLen(), nil
+	case "ecdsa-sha2-nistp256", "sk-ecdsa-sha2-nistp256@openssh.com":
+		return 256, nil
+	case "ecdsa-sha2-nistp384":
+		return 384, nil
+	case "ecdsa-sha2-nistp521":
+		return 521, nil
+	case "ssh-ed25519", "sk-ssh-ed25519@openssh.com":
+		return 256, nil
+	}
+	return 0, errors.Errorf("unknown ssh key algorithm %q", algorithm)
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ReadSecret prompts for a secret in stderr and reads it from stdin, without echo
+// when stdin is a terminal
+func ReadSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		secret, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", errors.Wrap(err, "reading secret")
+		}
+		return strings.TrimSpace(string(secret)), nil
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "reading secret")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// ReadTokenFile returns the token of the first line of the file
+func ReadTokenFile(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading token file %q", file)
+	}
+	token := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	if token == "" {
+		return "", errors.Errorf("empty token file %q", file)
+	}
+	return token, nil
+}
+
+// CredentialHelper runs the shell command with the get argument, like git
+// credential helpers, writing the protocol and host of the api url to its stdin
+// and returns the password of its output, or the output when it is a single value
+func CredentialHelper(command, apiURL string) (string, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing url %q", apiURL)
+	}
+	cmd := exec.Command("sh", "-c", command+" get")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\npath=%s\n\n", u.Scheme, u.Host, strings.Trim(u.Path, "/")))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "running credential helper %q", command)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "password=") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "password=")), nil
+		}
+	}
+	if len(lines) == 1 && lines[0] != "" && !strings.Contains(lines[0], "=") {
+		return strings.TrimSpace(lines[0]), nil
+	}
+	return "", errors.Errorf("no password in credential helper %q output", command)
+}
+
+// tokenStoreIterations is the PBKDF2 iterations deriving the token store key
+const tokenStoreIterations = 310000
+
+// TokenStore is a file of tokens encrypted with AES-GCM, with a key derived from
+// a passphrase
+type TokenStore struct {
+	file    string
+	Salt    string            `json:"salt"`
+	Tokens  map[string]string `json:"tokens"`
+	derived []byte
+}
+
+// OpenTokenStore reads the token store file, which is empty when it does not exist
+func OpenTokenStore(file string) (*TokenStore, error) {
+	store := &TokenStore{file: file, Tokens: make(map[string]string)}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, errors.Wrap(err, "creating token store salt")
+		}
+		store.Salt = base64.StdEncoding.EncodeToString(salt)
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading token store %q", file)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, errors.Wrapf(err, "parsing token store %q", file)
+	}
+	if store.Tokens == nil {
+		store.Tokens = make(map[string]string)
+	}
+	return store, nil
+}
+
+// File returns the store file
+func (s *TokenStore) File() string {
+	return s.file
+}
+
+// Has reports if the store has the token of the name
+func (s *TokenStore) Has(name string) bool {
+	_, ok := s.Tokens[name]
+	return ok
+}
+
+// Unlock derives the store key from the passphrase
+func (s *TokenStore) Unlock(passphrase string) error {
+	salt, err := base64.StdEncoding.DecodeString(s.Salt)
+	if err != nil {
+		return errors.Wrapf(err, "decoding token store %q salt", s.file)
+	}
+	s.derived = pbkdf2.Key([]byte(passphrase), salt, tokenStoreIterations, 32, sha256.New)
+	return nil
+}
+
+func (s *TokenStore) aead() (cipher.AEAD, error) {
+	if s.derived == nil {
+		return nil, errors.New("token store locked")
+	}
+	block, err := aes.NewCipher(s.derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Get decrypts the token of the name
+func (s *TokenStore) Get(name string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s.Tokens[name])
+	if err != nil {
+		return "", errors.Wrapf(err, "decoding token %q", name)
+	}
+	gcm, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.Errorf("invalid token %q", name)
+	}
+	token, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return "", errors.Errorf("decrypting token %q: wrong passphrase", name)
+	}
+	return string(token), nil
+}
+
+// Set encrypts the token of the name
+func (s *TokenStore) Set(name, token string) error {
+	gcm, err := s.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "creating nonce")
+	}
+	s.Tokens[name] = base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(token), []byte(name)))
+	return nil
+}
+
+// Delete removes the token of the name
+func (s *TokenStore) Delete(name string) {
+	delete(s.Tokens, name)
+}
+
+// Save writes the store file, readable only by the user
+func (s *TokenStore) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding token store")
+	}
+	if err := ioutil.WriteFile(s.file, data, 0600); err != nil {
+		return errors.Wrapf(err, "writing token store %q", s.file)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+// redacted replaces the secrets in the traces
+const redacted = "[REDACTED]"
+
+// traceBodyLimit is the maximum body size logged by the traces
+const traceBodyLimit = 4096
+
+// secretHeaders are the headers with credentials
+var secretHeaders = map[string]bool{
+	"Private-Token":  true,
+	"Job-Token":      true,
+	"Authorization":  true,
+	"Cookie":         true,
+	"Set-Cookie":     true,
+	"X-Gitlab-Token": true,
+}
+
+// secretName matches the query parameters and body fields with secrets
+var secretName = regexp.MustCompile(`(?i)token|password|secret|passphrase`)
+
+// IsSecretName reports if the setting or field name is of a secret
+func IsSecretName(name string) bool {
+	return secretName.MatchString(name)
+}
+
+// variableSecretName matches the fields with secrets of the CI/CD variables requests,
+// whose value is a secret whatever its key
+var variableSecretName = regexp.MustCompile(`(?i)token|password|secret|passphrase|^value$`)
+
+// variablesPath matches the paths of the CI/CD variables api
+var variablesPath = regexp.MustCompile(`/variables(/|$)`)
+
+// secretNames returns the pattern of the fields with secrets of the request url
+func secretNames(u *url.URL) *regexp.Regexp {
+	if variablesPath.MatchString(u.Path) {
+		return variableSecretName
+	}
+	return secretName
+}
+
+// rateLimitHeaders are the rate limit headers of the responses
+var rateLimitHeaders = []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+
+// TraceTransport logs the method, url, status, latency and rate limits of the
+// requests of the next transport, and their bodies with Body, redacting the
+// secrets, and records them in the HAR when it is not nil
+type TraceTransport struct {
+	Next http.RoundTripper
+	Body bool
+	HAR  *HAR
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && (t.Body || t.HAR != nil) {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading request body")
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	start := time.Now()
+	res, err := next.RoundTrip(req)
+	latency := time.Since(start)
+
+	ctx := log.WithFields(log.Fields{
+		"method":  req.Method,
+		"url":     RedactURL(req.URL),
+		"latency": latency.Round(time.Millisecond).String(),
+	})
+	if err != nil {
+		ctx.WithError(err).Warn("http request failed")
+		if t.HAR != nil {
+			t.HAR.add(start, latency, req, reqBody, nil, nil)
+		}
+		return nil, err
+	}
+	var resBody []byte
+	if t.Body || t.HAR != nil {
+		resBody, err = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading response body")
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	}
+	ctx = ctx.WithField("status", res.StatusCode)
+	for _, header := range rateLimitHeaders {
+		if value := res.Header.Get(header); value != "" {
+			ctx = ctx.WithField(strings.ToLower(header), value)
+		}
+	}
+	if t.Body {
+		if len(reqBody) > 0 {
+			ctx = ctx.WithField("request", traceBody(req.URL, req.Header.Get("Content-Type"), reqBody))
+		}
+		ctx = ctx.WithField("response", traceBody(req.URL, res.Header.Get("Content-Type"), resBody))
+	}
+	ctx.Info("http request")
+	if t.HAR != nil {
+		t.HAR.add(start, latency, req, reqBody, res, resBody)
+	}
+	return res, nil
+}
+
+// RedactURL returns the url with the secret query parameters redacted
+func RedactURL(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	secrets := secretNames(u)
+	for name := range query {
+		if secrets.MatchString(name) {
+			query.Set(name, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// RedactHeaders returns the headers with the credentials redacted
+func RedactHeaders(headers http.Header) http.Header {
+	res := make(http.Header, len(headers))
+	for name, values := range headers {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			res[name] = []string{redacted}
+		} else {
+			res[name] = values
+		}
+	}
+	return res
+}
+
+// RedactBody returns the JSON or form body of the request url with the secret fields
+// redacted, or the body when it is not JSON nor a form
+func RedactBody(u *url.URL, contentType string, body []byte) []byte {
+	secrets := secretNames(u)
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(redacted)
+		}
+		for name := range form {
+			if secrets.MatchString(name) {
+				form.Set(name, redacted)
+			}
+		}
+		return []byte(form.Encode())
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body
+	}
+	redactJSON(secrets, data)
+	res, err := json.Marshal(data)
+	if err != nil {
+		return body
+	}
+	return res
+}
+
+func redactJSON(secrets *regexp.Regexp, data interface{}) {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		for name, field := range value {
+			if _, ok := field.(string); ok && secrets.MatchString(name) {
+				value[name] = redacted
+			} else {
+				redactJSON(secrets, field)
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			redactJSON(secrets, item)
+		}
+	}
+}
+
+func isText(contentType string) bool {
+	return strings.Contains(contentType, "json") || strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "x-www-form-urlencoded")
+}
+
+// traceBody returns the redacted text body up to traceBodyLimit, or the size of the
+// other bodies, like the multipart ones
+func traceBody(u *url.URL, contentType string, body []byte) string {
+	if len(body) > 0 && contentType != "" && !isText(contentType) {
+		return "<" + strconv.Itoa(len(body)) + " bytes " + contentType + ">"
+	}
+	text := string(RedactBody(u, contentType, body))
+	if len(text) > traceBodyLimit {
+		text = text[:traceBodyLimit] + "..."
+	}
+	return text
+}
+
+// HAR records the traced requests in the HTTP Archive format
+type HAR struct {
+	mu      sync.Mutex
+	creator string
+	version string
+	entries []*harEntry
+}
+
+// NewHAR creates an HTTP Archive of the creator
+func NewHAR(creator, version string) *HAR {
+	return &HAR{creator: creator, version: version, entries: make([]*harEntry, 0)}
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *harRequest  `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *harTimings  `json:"timings"`
+}
+
+func harHeaders(headers http.Header) []*harNameValue {
+	res := make([]*harNameValue, 0, len(headers))
+	for name, values := range RedactHeaders(headers) {
+		for _, value := range values {
+			res = append(res, &harNameValue{Name: name, Value: value})
+		}
+	}
+	return res
+}
+
+// harText returns the redacted text body, empty when it is not text
+func harText(u *url.URL, contentType string, body []byte) string {
+	if !isText(contentType) {
+		return ""
+	}
+	return string(RedactBody(u, contentType, body))
+}
+
+func (h *HAR) add(start time.Time, latency time.Duration, req *http.Request, reqBody []byte, res *http.Response, resBody []byte) {
+	u, _ := url.Parse(RedactURL(req.URL))
+	query := make([]*harNameValue, 0)
+	for name, values := range u.Query() {
+		for _, value := range values {
+			query = append(query, &harNameValue{Name: name, Value: value})
+		}
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	entry := &harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: &harRequest{
+			Method:      req.Method,
+			URL:         u.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []*harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: query,
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: &harResponse{
+			Cookies:     []*harNameValue{},
+			Headers:     []*harNameValue{},
+			Content:     &harContent{MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: &harTimings{Wait: ms},
+	}
+	if len(reqBody) > 0 {
+		contentType := req.Header.Get("Content-Type")
+		entry.Request.PostData = &harPostData{MimeType: contentType, Text: harText(req.URL, contentType, reqBody)}
+	}
+	if res != nil {
+		contentType := res.Header.Get("Content-Type")
+		entry.Response.Status = res.StatusCode
+		entry.Response.StatusText = http.StatusText(res.StatusCode)
+		entry.Response.HTTPVersion = res.Proto
+		entry.Response.Headers = harHeaders(res.Header)
+		entry.Response.Content = &harContent{Size: len(resBody), MimeType: contentType, Text: harText(req.URL, contentType, resBody)}
+		entry.Response.RedirectURL = res.Header.Get("Location")
+		entry.Response.BodySize = len(resBody)
+	}
+	h.mu.Lock()
+	h.entries = append(h.entries, entry)
+	h.mu.Unlock()
+}
+
+// Write saves the HTTP Archive in the file
+func (h *HAR) Write(file string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	archive := map[string]interface{}{
+		"log": map[string]interface{}{
+			"version": "1.2",
+			"creator": map[string]string{"name": h.creator, "version": h.version},
+			"entries": h.entries,
+		},
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding HAR")
+	}
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		return errors.Wrapf(err, "writing HAR file %q", file)
+	}
+	return nil
+}
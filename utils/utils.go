@@ -1,13 +1,12 @@
 package utils
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
+	"strings"
 
 	"github.com/apex/log"
 	"github.com/pkg/errors"
@@ -48,6 +47,23 @@ func NotImplementedError(function string) error {
 	return errors.Errorf("%s: not implemented: PRs are welcome at https://github.com/janusky/gitlab-api-client", function)
 }
 
+// stdin is shared by the prompts, to read several lines from a pipe
+var stdin = bufio.NewReader(os.Stdin)
+
+// Confirm asks a yes/no question on Stderr and reads the answer from Stdin
+func Confirm(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		return false, errors.Wrap(err, "reading confirmation")
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
 // IsDebugEnabled returns true if current log is Level set Debug
 func IsDebugEnabled() bool {
 	if logger, ok := log.Log.(*log.Logger); ok {
@@ -55,23 +71,3 @@ func IsDebugEnabled() bool {
 	}
 	return false
 }
-
-// HTTPClient creates a new http client
-func HTTPClient(trustedCertificates [][]byte) (*http.Client, error) {
-	pool, err := x509.SystemCertPool()
-	if err != nil {
-		return nil, errors.Wrap(err, "creating system certificate pool")
-	}
-	for _, cert := range trustedCertificates {
-		if !pool.AppendCertsFromPEM(cert) {
-			return nil, errors.Errorf("no certificates was parsed from %q", cert)
-		}
-	}
-	return &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: pool,
-			},
-		},
-	}, nil
-}